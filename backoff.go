@@ -0,0 +1,43 @@
+package tcc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+// WithBackOff sets retry policy of confirm and cancel, e.g. constant or fully custom backoff,
+// instead of exponential backoff limited by WithMaxRetries, which is ignored. b limits the retries by itself.
+// b is shared by every service and director the option is passed to, so they use up the same retries
+// and reset each other; use WithBackOffFactory to give each retried call its own policy.
+func WithBackOff(b backoff.BackOff) Option {
+	locked := &lockedBackOff{b: b}
+	return WithBackOffFactory(func() backoff.BackOff { return locked })
+}
+
+// WithBackOffFactory sets function returning a new retry policy of confirm and cancel like WithBackOff.
+// newBackOff is called for every retried call, so that services retried concurrently never share the state of a policy.
+func WithBackOffFactory(newBackOff func() backoff.BackOff) Option {
+	return func(d *director) {
+		d.backoff = newBackOff
+	}
+}
+
+// lockedBackOff is BackOff safe for concurrent use.
+type lockedBackOff struct {
+	mu sync.Mutex
+	b  backoff.BackOff
+}
+
+func (l *lockedBackOff) NextBackOff() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.b.NextBackOff()
+}
+
+func (l *lockedBackOff) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.b.Reset()
+}
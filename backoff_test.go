@@ -0,0 +1,75 @@
+package tcc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+func TestWithBackOff(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  Option
+		want int
+	}{
+		{
+			name: "WithBackOff",
+			opt:  WithBackOff(backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 2)),
+			want: 3,
+		},
+		{
+			name: "WithBackOffFactory",
+			opt: WithBackOffFactory(func() backoff.BackOff {
+				return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 3)
+			}),
+			want: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			d := NewDirector([]*Service{
+				NewService("s1", func() error { return nil }, func() error {
+					calls++
+					return errors.New("test")
+				}, func() error { return nil }),
+			}, WithMaxRetries(10), tt.opt)
+			if err := d.Direct(); err == nil {
+				t.Fatal("director.Direct() error = nil, want error")
+			}
+			if calls != tt.want {
+				t.Errorf("confirm called %v times, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithBackOffFactory(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	confirm := func(name string) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls[name]++
+			return errors.New("test")
+		}
+	}
+	d := NewDirector([]*Service{
+		NewService("s1", func() error { return nil }, confirm("s1"), func() error { return nil }),
+		NewService("s2", func() error { return nil }, confirm("s2"), func() error { return nil }),
+	}, WithMaxRetries(10), WithBackOffFactory(func() backoff.BackOff {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 3)
+	}))
+	if err := d.Direct(); err == nil {
+		t.Fatal("director.Direct() error = nil, want error")
+	}
+	// each service is retried with its own policy
+	for _, name := range []string{"s1", "s2"} {
+		if calls[name] != 4 {
+			t.Errorf("confirm of %s called %v times, want %v", name, calls[name], 4)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package tcc
+
+import "errors"
+
+// ErrCallLimitExceeded is returned when a transaction has made as many phase calls as WithMaxCalls allows.
+var ErrCallLimitExceeded = errors.New("transaction exceeded its limit of phase calls")
+
+// WithMaxCalls caps the total number of phase calls the transaction makes to n,
+// counting validate, try, confirm and cancel of every service including retries.
+// It guards participants against retry storms caused by misconfigured backoff.
+// Calls over the cap fail with ErrCallLimitExceeded without retries,
+// so the transaction fails safe to StatusFailed and needs to be resumed or fixed manually.
+// n <= 0 means no limit.
+func WithMaxCalls(n int) Option {
+	return func(d *director) {
+		d.maxCalls = int64(n)
+	}
+}
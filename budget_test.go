@@ -0,0 +1,47 @@
+package tcc
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+func TestWithMaxCalls(t *testing.T) {
+	var calls, failing int32 = 0, 1
+	phase := func() error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}
+	d := newDirector([]*Service{
+		NewService("s1", phase, func() error {
+			atomic.AddInt32(&calls, 1)
+			if atomic.LoadInt32(&failing) == 1 {
+				return errors.New("misconfigured")
+			}
+			return nil
+		}, phase),
+		NewService("s2", phase, phase, phase),
+	}, WithMaxCalls(5))
+	d.backoff = func() backoff.BackOff { return &backoff.ZeroBackOff{} }
+
+	err := d.Wait()
+	if !errors.Is(err, ErrCallLimitExceeded) {
+		t.Errorf("director.Wait() error = %v, want %v", err, ErrCallLimitExceeded)
+	}
+	if got := atomic.LoadInt32(&calls); got != 5 {
+		t.Errorf("calls = %d, want 5", got)
+	}
+	if got := d.Status(); got != StatusFailed {
+		t.Errorf("director.Status() = %v, want %v", got, StatusFailed)
+	}
+
+	atomic.StoreInt32(&failing, 0)
+	if err := d.Resume(); err != nil {
+		t.Errorf("director.Resume() error = %v", err)
+	}
+	if got := d.Status(); got != StatusConfirmed {
+		t.Errorf("director.Status() after Resume() = %v, want %v", got, StatusConfirmed)
+	}
+}
@@ -0,0 +1,120 @@
+package tcc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+// Code classifies errors returned by services, and drives retry/rollback decisions of director.
+type Code int
+
+const (
+	// CodeUnknown is the code of errors which have no code, treated as CodeRetryable.
+	CodeUnknown Code = iota
+
+	// CodeRetryable means the call may succeed if retried, e.g. network errors.
+	CodeRetryable
+
+	// CodePermanent means the call never succeeds even if retried, e.g. malformed request.
+	CodePermanent
+
+	// CodeConflict means the call conflicts with current state of the participant,
+	// e.g. the resource is reserved by other transaction. It is not retried.
+	CodeConflict
+
+	// CodeNotFound means the participant does not know the branch.
+	// Cancel failed with CodeNotFound is treated as succeeded, because there is nothing to revert.
+	// Otherwise it is not retried.
+	CodeNotFound
+
+	// CodeThrottled means the participant is overloaded, the call is retried.
+	CodeThrottled
+)
+
+func (c Code) String() string {
+	switch c {
+	case CodeUnknown:
+		return "unknown"
+	case CodeRetryable:
+		return "retryable"
+	case CodePermanent:
+		return "permanent"
+	case CodeConflict:
+		return "conflict"
+	case CodeNotFound:
+		return "not found"
+	case CodeThrottled:
+		return "throttled"
+	default:
+		return fmt.Sprintf("Code(%d)", int(c))
+	}
+}
+
+// Retryable returns if errors with the code should be retried.
+func (c Code) Retryable() bool {
+	switch c {
+	case CodeUnknown, CodeRetryable, CodeThrottled:
+		return true
+	default:
+		return false
+	}
+}
+
+type codeError struct {
+	code Code
+	err  error
+}
+
+func (e *codeError) Error() string { return e.err.Error() }
+
+func (e *codeError) Unwrap() error { return e.err }
+
+// WithCode annotates err with code.
+// Services can return it from try/confirm/cancel to tell director how to handle the error.
+func WithCode(err error, code Code) error {
+	if err == nil {
+		return nil
+	}
+	return &codeError{code: code, err: err}
+}
+
+// Permanent annotates err with CodePermanent, so that it is never retried,
+// e.g. for business errors like insufficient balance.
+func Permanent(err error) error {
+	return WithCode(err, CodePermanent)
+}
+
+// CodeOf returns the code annotated to err by WithCode, CodePermanent for backoff.Permanent, or CodeUnknown.
+func CodeOf(err error) Code {
+	var e *codeError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	var p *backoff.PermanentError
+	if errors.As(err, &p) {
+		return CodePermanent
+	}
+	return CodeUnknown
+}
+
+// HTTPStatusCode maps HTTP status code returned by a participant to Code.
+// Status codes which are not errors are mapped to CodeUnknown.
+func HTTPStatusCode(status int) Code {
+	switch {
+	case status < http.StatusBadRequest:
+		return CodeUnknown
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusConflict:
+		return CodeConflict
+	case status == http.StatusTooManyRequests:
+		return CodeThrottled
+	case status == http.StatusRequestTimeout, status >= http.StatusInternalServerError:
+		return CodeRetryable
+	default:
+		return CodePermanent
+	}
+}
@@ -0,0 +1,163 @@
+package tcc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+func TestCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{
+			name: "no code",
+			err:  errors.New("test"),
+			want: CodeUnknown,
+		},
+		{
+			name: "with code",
+			err:  WithCode(errors.New("test"), CodeConflict),
+			want: CodeConflict,
+		},
+		{
+			name: "wrapped",
+			err:  fmt.Errorf("wrapped: %w", WithCode(errors.New("test"), CodeThrottled)),
+			want: CodeThrottled,
+		},
+		{
+			name: "permanent",
+			err:  Permanent(errors.New("test")),
+			want: CodePermanent,
+		},
+		{
+			name: "backoff permanent",
+			err:  fmt.Errorf("wrapped: %w", backoff.Permanent(errors.New("test"))),
+			want: CodePermanent,
+		},
+		{
+			name: "nil",
+			err:  nil,
+			want: CodeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeOf(tt.err); got != tt.want {
+				t.Errorf("CodeOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithCode_Nil(t *testing.T) {
+	if err := WithCode(nil, CodePermanent); err != nil {
+		t.Errorf("WithCode() = %v, want nil", err)
+	}
+}
+
+func TestHTTPStatusCode(t *testing.T) {
+	tests := []struct {
+		status int
+		want   Code
+	}{
+		{status: http.StatusOK, want: CodeUnknown},
+		{status: http.StatusBadRequest, want: CodePermanent},
+		{status: http.StatusNotFound, want: CodeNotFound},
+		{status: http.StatusConflict, want: CodeConflict},
+		{status: http.StatusRequestTimeout, want: CodeRetryable},
+		{status: http.StatusTooManyRequests, want: CodeThrottled},
+		{status: http.StatusInternalServerError, want: CodeRetryable},
+		{status: http.StatusServiceUnavailable, want: CodeRetryable},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			if got := HTTPStatusCode(tt.status); got != tt.want {
+				t.Errorf("HTTPStatusCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_director_Direct_Code(t *testing.T) {
+	tests := []struct {
+		name         string
+		confirmErr   error
+		tryErr       error
+		cancelErr    error
+		opts         []Option
+		wantErr      bool
+		wantAttempts int
+	}{
+		{
+			name:         "permanent confirm error is not retried",
+			confirmErr:   WithCode(errors.New("test"), CodePermanent),
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "throttled confirm error is retried",
+			confirmErr:   WithCode(errors.New("test"), CodeThrottled),
+			wantErr:      true,
+			wantAttempts: 2,
+		},
+		{
+			name:         "error classified as not retryable is not retried",
+			confirmErr:   errors.New("insufficient balance"),
+			opts:         []Option{WithRetryClassifier(func(err error) bool { return err.Error() != "insufficient balance" })},
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+		{
+			name:         "error classified as retryable is retried",
+			confirmErr:   Permanent(errors.New("test")),
+			opts:         []Option{WithRetryClassifier(func(err error) bool { return true })},
+			wantErr:      true,
+			wantAttempts: 2,
+		},
+		{
+			name:      "not found cancel error is treated as canceled",
+			tryErr:    errors.New("test"),
+			cancelErr: WithCode(errors.New("test"), CodeNotFound),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			d := NewDirector([]*Service{
+				NewService(
+					"s1",
+					func() error { return nil },
+					func() error { attempts++; return tt.confirmErr },
+					func() error { return tt.cancelErr },
+				),
+				NewService(
+					"s2",
+					func() error { return tt.tryErr },
+					func() error { return nil },
+					func() error { return nil },
+				),
+			}, append(tt.opts, WithMaxRetries(1))...)
+			err := d.Direct()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("director.Direct() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.confirmErr != nil && attempts != tt.wantAttempts {
+				t.Errorf("confirm() called %v times, want %v", attempts, tt.wantAttempts)
+			}
+			if tt.cancelErr != nil {
+				e, ok := err.(*Error)
+				if !ok || e.FailedPhase() != ErrTryFailed {
+					t.Errorf("director.Direct() error = %v, want try failure", err)
+				}
+			}
+		})
+	}
+}
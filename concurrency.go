@@ -0,0 +1,160 @@
+package tcc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// aimdBackoffRatio is the ratio the adaptive limit is multiplied by on overload.
+	aimdBackoffRatio = 0.9
+)
+
+// limiter limits phase calls of services in flight.
+type limiter interface {
+	// acquire waits for a free slot, or returns error when ctx is done.
+	acquire(ctx context.Context) error
+	// release is called after the call with the result of it.
+	release(err error, latency time.Duration)
+}
+
+// ConcurrencyLimiter limits phase calls in flight at the same time of the services it is set to.
+// Share one by every service calling the same participant, e.g. by creating it with the participant's client,
+// so that a small participant is protected from big fan-outs of many transactions.
+type ConcurrencyLimiter struct {
+	limiter limiter
+}
+
+// NewConcurrencyLimiter returns ConcurrencyLimiter which allows n calls in flight.
+// n <= 0 means no limit.
+func NewConcurrencyLimiter(n int) *ConcurrencyLimiter {
+	if n <= 0 {
+		return &ConcurrencyLimiter{}
+	}
+	return &ConcurrencyLimiter{limiter: make(semaphore, n)}
+}
+
+// NewAdaptiveConcurrencyLimiter returns ConcurrencyLimiter with a limit adjusted to feedback from the participant.
+// The limit starts from min, grows additively while calls succeed within maxLatency,
+// and shrinks multiplicatively when calls are slower or fail with retryable errors, staying between min and max.
+// min <= 0 or max < min means no limit.
+func NewAdaptiveConcurrencyLimiter(min, max int, maxLatency time.Duration) *ConcurrencyLimiter {
+	if min <= 0 || max < min {
+		return &ConcurrencyLimiter{}
+	}
+	return &ConcurrencyLimiter{limiter: newAIMD(min, max, maxLatency)}
+}
+
+// WithConcurrencyLimiter limits phase calls of the service in flight by l,
+// together with every other service l is set to.
+func WithConcurrencyLimiter(l *ConcurrencyLimiter) ServiceOption {
+	return func(s *Service) {
+		if l != nil {
+			s.limiter = l.limiter
+		}
+	}
+}
+
+// call calls f of the service, waiting for the limiter if concurrency of the service is limited.
+func (s *Service) call(ctx context.Context, f PhaseFunc) error {
+	if s.limiter == nil {
+		return f(ctx)
+	}
+	if err := s.limiter.acquire(ctx); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := f(ctx)
+	s.limiter.release(err, time.Since(start))
+	return err
+}
+
+type semaphore chan struct{}
+
+func (s semaphore) acquire(ctx context.Context) error {
+	select {
+	case s <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s semaphore) release(error, time.Duration) { <-s }
+
+// aimd is limiter with additive increase / multiplicative decrease limit.
+type aimd struct {
+	min, max   float64
+	maxLatency time.Duration
+
+	limit    float64
+	inFlight int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+}
+
+func newAIMD(min, max int, maxLatency time.Duration) *aimd {
+	l := &aimd{
+		min:        float64(min),
+		max:        float64(max),
+		maxLatency: maxLatency,
+		limit:      float64(min),
+	}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+func (l *aimd) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= int(l.limit) {
+		// wake up waiting loop when ctx is done
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() {
+			select {
+			case <-ctx.Done():
+				l.mu.Lock()
+				l.cond.Broadcast()
+				l.mu.Unlock()
+			case <-stop:
+			}
+		}()
+	}
+	for l.inFlight >= int(l.limit) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.cond.Wait()
+	}
+	l.inFlight++
+	return nil
+}
+
+func (l *aimd) release(err error, latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	overloaded := latency > l.maxLatency || (err != nil && CodeOf(err).Retryable())
+	if overloaded {
+		l.limit *= aimdBackoffRatio
+		if l.limit < l.min {
+			l.limit = l.min
+		}
+	} else if err == nil {
+		l.limit += 1 / l.limit
+		if l.limit > l.max {
+			l.limit = l.max
+		}
+	}
+	l.cond.Broadcast()
+}
+
+// current returns current limit.
+func (l *aimd) current() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
@@ -0,0 +1,135 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithConcurrencyLimiter(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	phase := func() error {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return nil
+	}
+
+	limiter := NewConcurrencyLimiter(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d := NewDirector([]*Service{
+				NewService("s1", phase, phase, phase, WithConcurrencyLimiter(limiter)),
+			})
+			if err := d.Direct(); err != nil {
+				t.Errorf("director.Direct() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxInFlight != 2 {
+		t.Errorf("max calls in flight = %v, want 2", maxInFlight)
+	}
+}
+
+func Test_aimd(t *testing.T) {
+	tests := []struct {
+		name      string
+		results   []error
+		latency   time.Duration
+		wantLimit int
+	}{
+		{
+			name:      "grows while succeeding",
+			results:   make([]error, 20),
+			latency:   time.Millisecond,
+			wantLimit: 6,
+		},
+		{
+			name:      "capped by max",
+			results:   make([]error, 200),
+			latency:   time.Millisecond,
+			wantLimit: 10,
+		},
+		{
+			name:      "shrinks when slow",
+			results:   make([]error, 20),
+			latency:   time.Second,
+			wantLimit: 2,
+		},
+		{
+			name:      "shrinks on retryable errors",
+			results:   []error{errors.New("test"), errors.New("test")},
+			latency:   time.Millisecond,
+			wantLimit: 2,
+		},
+		{
+			name:      "ignores permanent errors",
+			results:   []error{WithCode(errors.New("test"), CodeConflict)},
+			latency:   time.Millisecond,
+			wantLimit: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newAIMD(2, 10, 100*time.Millisecond)
+			for _, err := range tt.results {
+				_ = l.acquire(context.Background())
+				l.release(err, tt.latency)
+			}
+			if got := l.current(); got != tt.wantLimit {
+				t.Errorf("aimd limit = %v, want %v", got, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func Test_aimd_acquire(t *testing.T) {
+	l := newAIMD(1, 1, time.Second)
+	_ = l.acquire(context.Background())
+	acquired := make(chan struct{})
+	go func() {
+		_ = l.acquire(context.Background())
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatalf("acquired beyond the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	l.release(nil, time.Millisecond)
+	<-acquired
+}
+
+func Test_limiter_acquire_Canceled(t *testing.T) {
+	tests := []struct {
+		name string
+		l    limiter
+	}{
+		{name: "semaphore", l: make(semaphore, 1)},
+		{name: "aimd", l: newAIMD(1, 1, time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = tt.l.acquire(context.Background())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			if err := tt.l.acquire(ctx); err != context.DeadlineExceeded {
+				t.Errorf("limiter.acquire() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
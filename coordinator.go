@@ -0,0 +1,129 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is the cause of CoordinatorError returned by Submit when the queue of the coordinator is full.
+	ErrQueueFull = errors.New("coordinator queue is full")
+
+	// ErrCoordinatorClosed is the cause of CoordinatorError returned by Submit after Shutdown is called.
+	ErrCoordinatorClosed = errors.New("coordinator is shut down")
+)
+
+// TxHandle is a transaction submitted to Coordinator.
+type TxHandle interface {
+	// TxId returns ID of the transaction.
+	TxId() string
+
+	// Status returns the current status of the transaction.
+	Status() Status
+
+	// State returns the current status of the transaction and the progress of every service.
+	State() *State
+
+	// Abort makes the transaction cancel instead of confirm, like Transaction.Abort.
+	// A transaction aborted in the queue is canceled without calling any service.
+	Abort()
+
+	// Wait waits until the transaction finishes, and returns the same error as Director.Direct.
+	Wait() error
+}
+
+// Coordinator directs transactions by a bounded pool of workers, taking them from a bounded queue.
+type Coordinator struct {
+	opts  []Option
+	queue chan *txHandle
+	wg    sync.WaitGroup
+
+	// mu guards closed and sending to queue
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewCoordinator returns Coordinator which directs at most workers transactions at the same time,
+// and queues at most queueSize transactions waiting for a worker. workers <= 0 means 1 worker.
+// opts are used for the director of every transaction.
+func NewCoordinator(workers, queueSize int, opts ...Option) *Coordinator {
+	if workers <= 0 {
+		workers = 1
+	}
+	c := &Coordinator{opts: opts, queue: make(chan *txHandle, queueSize)}
+	c.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go c.work()
+	}
+	return c
+}
+
+func (c *Coordinator) work() {
+	defer c.wg.Done()
+	for h := range c.queue {
+		h.err = h.d.DirectContext(context.Background())
+		close(h.done)
+	}
+}
+
+// Submit queues the transaction of services, and returns at once.
+// It fails with ErrQueueFull instead of blocking when the queue is full, so that callers can shed load.
+// The transaction directs copies of services, so the same services can be submitted again while it is in flight.
+func (c *Coordinator) Submit(services ...*Service) (TxHandle, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return nil, &CoordinatorError{op: "submit transaction", err: ErrCoordinatorClosed}
+	}
+	copies := make([]*Service, len(services))
+	for i, s := range services {
+		copied := *s
+		copies[i] = &copied
+	}
+	h := &txHandle{d: newDirector(copies, c.opts...), done: make(chan struct{})}
+	select {
+	case c.queue <- h:
+		return h, nil
+	default:
+		return nil, &CoordinatorError{op: "submit transaction", err: ErrQueueFull}
+	}
+}
+
+// Shutdown stops accepting transactions, and waits until every submitted transaction finishes or ctx is done.
+// Transactions in the queue are still directed. Shutdown returns ctx.Err() if ctx is done first.
+func (c *Coordinator) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.queue)
+	}
+	c.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type txHandle struct {
+	d    *director
+	done chan struct{}
+	err  error
+}
+
+func (h *txHandle) TxId() string   { return h.d.TxId() }
+func (h *txHandle) Status() Status { return h.d.Status() }
+func (h *txHandle) State() *State  { return h.d.State() }
+func (h *txHandle) Abort()         { h.d.Abort() }
+
+func (h *txHandle) Wait() error {
+	<-h.done
+	return h.err
+}
@@ -0,0 +1,87 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCoordinator(t *testing.T) {
+	release := make(chan struct{})
+	blocking := func() *Service {
+		return NewService("s1", func() error { <-release; return nil }, func() error { return nil }, func() error { return nil })
+	}
+	c := NewCoordinator(1, 1)
+	running, err := c.Submit(blocking())
+	if err != nil {
+		t.Fatalf("Coordinator.Submit() error = %v", err)
+	}
+	// wait until the worker takes the first one, so the second one stays in the queue
+	for running.Status() == StatusNotStarted {
+		time.Sleep(time.Millisecond)
+	}
+	queued, err := c.Submit(blocking())
+	if err != nil {
+		t.Fatalf("Coordinator.Submit() error = %v", err)
+	}
+	if _, err := c.Submit(blocking()); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Coordinator.Submit() error = %v, want %v", err, ErrQueueFull)
+	}
+	queued.Abort()
+	close(release)
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Coordinator.Shutdown() error = %v", err)
+	}
+	if err := running.Wait(); err != nil || running.Status() != StatusConfirmed {
+		t.Errorf("running transaction error = %v, status = %v", err, running.Status())
+	}
+	if err := queued.Wait(); !errors.Is(err, ErrAborted) || queued.Status() != StatusCanceled {
+		t.Errorf("aborted transaction error = %v, status = %v", err, queued.Status())
+	}
+	var ce *CoordinatorError
+	if _, err := c.Submit(blocking()); !errors.Is(err, ErrCoordinatorClosed) || !errors.As(err, &ce) {
+		t.Errorf("Coordinator.Submit() after Shutdown error = %v, want %v", err, ErrCoordinatorClosed)
+	}
+}
+
+func TestCoordinator_Shutdown_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	c := NewCoordinator(1, 1)
+	if _, err := c.Submit(NewService("s1", func() error { <-release; return nil }, func() error { return nil }, func() error { return nil })); err != nil {
+		t.Fatalf("Coordinator.Submit() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Coordinator.Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCoordinator_Submit_sameServices(t *testing.T) {
+	c := NewCoordinator(0, 10)
+	services := []*Service{
+		NewService("s1", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+		NewService("s2", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+	}
+	var handles []TxHandle
+	for i := 0; i < 5; i++ {
+		h, err := c.Submit(services...)
+		if err != nil {
+			t.Fatalf("Coordinator.Submit() error = %v", err)
+		}
+		handles = append(handles, h)
+	}
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Coordinator.Shutdown() error = %v", err)
+	}
+	for _, h := range handles {
+		if err := h.Wait(); err != nil || h.Status() != StatusConfirmed {
+			t.Errorf("transaction %s error = %v, status = %v", h.TxId(), err, h.Status())
+		}
+	}
+	if status := services[0].Status(); status != ServiceNotStarted {
+		t.Errorf("submitted service is changed to %v", status)
+	}
+}
@@ -0,0 +1,66 @@
+package tcc
+
+import (
+	"strconv"
+	"time"
+)
+
+// TxDiff is a difference between two transactions found by DiffTx.
+type TxDiff struct {
+	// Service is the name of the service which differs, or empty if the transaction itself differs.
+	Service string
+
+	// Field is what differs, e.g. "Status", "StartedAt", "ConfirmSucceeded" or "TryResult".
+	// It is "Present" if the service is in only one of them.
+	Field string
+
+	// A and B are the values in each transaction.
+	A, B string
+}
+
+// DiffTx returns the differences of status, start time, and outcomes and try results of services
+// between two saved transactions, e.g. a failed one and a confirmed one of the same services,
+// to spot what is different about the failing case. Attempts of services are not compared, because they are not saved.
+// Services are matched by name, and differences are in the order of services of a, then ones only in b.
+func DiffTx(a, b *TxState) []TxDiff {
+	var diffs []TxDiff
+	add := func(service, field, va, vb string) {
+		if va != vb {
+			diffs = append(diffs, TxDiff{Service: service, Field: field, A: va, B: vb})
+		}
+	}
+	add("", "Status", a.Status.String(), b.Status.String())
+	add("", "StartedAt", formatTime(a.StartedAt), formatTime(b.StartedAt))
+	inB := map[string]ServiceState{}
+	for _, ss := range b.Services {
+		inB[ss.Name] = ss
+	}
+	inA := map[string]bool{}
+	for _, sa := range a.Services {
+		inA[sa.Name] = true
+		sb, ok := inB[sa.Name]
+		if !ok {
+			add(sa.Name, "Present", "true", "false")
+			continue
+		}
+		add(sa.Name, "TrySucceeded", strconv.FormatBool(sa.TrySucceeded), strconv.FormatBool(sb.TrySucceeded))
+		add(sa.Name, "ConfirmSucceeded", strconv.FormatBool(sa.ConfirmSucceeded), strconv.FormatBool(sb.ConfirmSucceeded))
+		add(sa.Name, "CancelSucceeded", strconv.FormatBool(sa.CancelSucceeded), strconv.FormatBool(sb.CancelSucceeded))
+		add(sa.Name, "TryResult", string(sa.TryResult), string(sb.TryResult))
+		add(sa.Name, "Isolation", string(sa.Isolation), string(sb.Isolation))
+	}
+	for _, sb := range b.Services {
+		if !inA[sb.Name] {
+			add(sb.Name, "Present", "false", "true")
+		}
+	}
+	return diffs
+}
+
+// formatTime formats t in RFC 3339, or returns empty string if t is zero.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
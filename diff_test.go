@@ -0,0 +1,35 @@
+package tcc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffTx(t *testing.T) {
+	startedAt := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := &TxState{TxId: "tx1", Status: StatusFailed, StartedAt: startedAt, Services: []ServiceState{
+		{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true, TryResult: []byte("r1")},
+		{Name: "s2", TrySucceeded: true},
+		{Name: "s3", TrySucceeded: true},
+	}}
+	b := &TxState{TxId: "tx2", Status: StatusConfirmed, Services: []ServiceState{
+		{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true, TryResult: []byte("r2")},
+		{Name: "s2", TrySucceeded: true, ConfirmSucceeded: true},
+		{Name: "s4", TrySucceeded: true, ConfirmSucceeded: true},
+	}}
+	want := []TxDiff{
+		{Field: "Status", A: "failed", B: "confirmed"},
+		{Field: "StartedAt", A: "2020-01-02T03:04:05Z", B: ""},
+		{Service: "s1", Field: "TryResult", A: "r1", B: "r2"},
+		{Service: "s2", Field: "ConfirmSucceeded", A: "false", B: "true"},
+		{Service: "s3", Field: "Present", A: "true", B: "false"},
+		{Service: "s4", Field: "Present", A: "false", B: "true"},
+	}
+	if got := DiffTx(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffTx() = %+v, want %+v", got, want)
+	}
+	if got := DiffTx(a, a); got != nil {
+		t.Errorf("DiffTx() of the same transaction = %+v, want nil", got)
+	}
+}
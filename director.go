@@ -1,12 +1,15 @@
 package tcc
 
 import (
+	"context"
 	"errors"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cenkalti/backoff/v3"
 	"github.com/rs/xid"
-	"golang.org/x/sync/errgroup"
 )
 
 // Option can set option to service
@@ -15,9 +18,47 @@ import (
 type Option func(s *director)
 
 // WithMaxRetries sets limitation of retry times
+// 0 means confirm and cancel are never retried.
 func WithMaxRetries(maxRetries uint64) Option {
 	return func(d *director) {
-		d.backoff = backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxRetries)
+		d.maxRetries = maxRetries
+	}
+}
+
+// WithTryRetries sets how many times try of a service is retried after it failed with a retryable error,
+// e.g. a network blip, instead of canceling the transaction at once. 0, the default, means try is never retried.
+// Retried try waits like confirm and cancel do, and needs to be idempotent, because the failed one may have reserved.
+// Use WithCode to tell errors which are not worth retrying.
+func WithTryRetries(tryRetries uint64) Option {
+	return func(d *director) {
+		d.tryRetries = tryRetries
+	}
+}
+
+// WithTxId sets ID of the transaction instead of a generated xid,
+// e.g. to correlate it with the request which started it.
+// It needs to be unique, because Store and ResourceLocker tell transactions apart by it.
+func WithTxId(txId string) Option {
+	return func(d *director) {
+		d.txId = txId
+	}
+}
+
+// WithDeterministicSchedule makes director call services one by one
+// in an order decided by seed, instead of concurrently.
+// It is meant for tests: a failure found with a seed can be reproduced exactly with the same seed.
+func WithDeterministicSchedule(seed int64) Option {
+	return func(d *director) {
+		d.schedule = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithOnComplete sets callback called exactly once after every service is confirmed,
+// e.g. to emit the domain event of the committed transaction.
+// It is not called if the transaction is canceled or failed, but is called when Resume confirms it.
+func WithOnComplete(f func(txId string)) Option {
+	return func(d *director) {
+		d.onComplete = f
 	}
 }
 
@@ -27,123 +68,407 @@ func WithMaxRetries(maxRetries uint64) Option {
 // If even one of the services' try fails, every service's cancel will be called.
 type Director interface {
 	Direct() error
+
+	// DirectContext is Direct with context, which is passed to every phase function.
+	// When ctx is done in try phase, the transaction is canceled.
+	// Cancel phase after try keeps values of ctx but not its deadline and cancellation,
+	// so that reservations are released even if try failed because ctx is done.
+	// When ctx is done in confirm phase, retries stop and
+	// the services not finished are reported as failed.
+	DirectContext(ctx context.Context) error
+
+	// DirectResult is DirectContext returning the outcome of the transaction,
+	// which tells the error of try phase apart from the error of cancel phase.
+	DirectResult(ctx context.Context) *Result
+
+	// DirectAsync is DirectResult in background, which returns at once.
+	// The channel receives the result when the transaction finishes, and is closed.
+	// ctx must outlive the caller if it returns first, e.g. not be the context of an HTTP request,
+	// otherwise the transaction is canceled or stops retrying when the caller returns.
+	DirectAsync(ctx context.Context) <-chan *Result
+
+	// TxId returns ID of the transaction, which is passed to phase functions in CallInfo,
+	// e.g. to log it or to pass it to participants as an idempotency key.
+	TxId() string
+
+	// State returns the status of the transaction and the progress of every service.
+	// It is safe to call while the transaction is in progress.
+	State() *State
 }
 
 type director struct {
+	// calls is accessed atomically, and kept first for 64-bit alignment
+	calls int64
+
+	txId     string
 	services []*Service
-	backoff  backoff.BackOff
+	// backoff returns retry policy of a confirm or cancel call, made of maxRetries and delays unless set by WithBackOff,
+	// a new one for each call so that services are retried concurrently
+	backoff     func() backoff.BackOff
+	maxRetries  uint64
+	tryRetries  uint64
+	retryable   func(error) bool
+	delays      func() backoff.BackOff
+	health      *HealthRegistry
+	recovery    *RecoveryLimiter
+	locker      ResourceLocker
+	lockWait    func() backoff.BackOff
+	schedule    *rand.Rand
+	store       Store
+	maxCalls    int64
+	metrics     Metrics
+	logger      Logger
+	middlewares []Middleware
+	events      EventSink
+	incidents   *IncidentTracker
+	eventSource string
+
+	// startedAt is when try phase started, which is saved to Store
+	startedAt time.Time
+	// tryErr is why the transaction is canceled in cancel phase
+	tryErr error
+
+	onComplete func(txId string)
+	completed  sync.Once
 
-	sync.Mutex
+	// mu guards status, aborted, confirmStarted, cancelTry, and the progress of services written in phases
+	mu             sync.Mutex
+	status         Status
+	aborted        bool
+	confirmStarted bool
+	cancelTry      context.CancelFunc
+
+	start sync.Once
+	done  chan struct{}
+	err   error
 }
 
 // NewDirector returns interface Director
 func NewDirector(services []*Service, opts ...Option) Director {
+	return newDirector(services, opts...)
+}
+
+func newDirector(services []*Service, opts ...Option) *director {
 	maxRetries := uint64(10)
-	txId := xid.New().String()
+	o := &director{
+		txId:       xid.New().String(),
+		services:   services,
+		maxRetries: maxRetries,
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
 	for _, service := range services {
-		service.txId = txId
+		service.txId = o.txId
 		service.tried = false
 		service.trySucceeded = false
+		service.lateTry = false
 		service.canceled = false
 		service.cancelSucceeded = false
 		service.confirmed = false
-		service.cancelSucceeded = false
+		service.confirmSucceeded = false
+		service.tryResult = nil
 	}
-	o := &director{
-		services: services,
-		backoff:  backoff.WithMaxRetries(backoff.NewExponentialBackOff(), maxRetries),
-		Mutex:    sync.Mutex{},
+	if o.delays == nil {
+		o.delays = func() backoff.BackOff { return backoff.NewExponentialBackOff() }
 	}
-	for _, opt := range opts {
-		opt(o)
+	if o.retryable == nil {
+		o.retryable = retryableCode
+	} else {
+		o.retryable = classifyDirectorErrors(o.retryable)
+	}
+	if o.backoff == nil {
+		o.backoff = func() backoff.BackOff {
+			if o.maxRetries == 0 {
+				return &backoff.StopBackOff{}
+			}
+			return backoff.WithMaxRetries(o.delays(), o.maxRetries)
+		}
 	}
 	return o
 }
 
+func (d *director) TxId() string {
+	return d.txId
+}
+
 // Direct can handle all the passed Service's transaction
 func (d *director) Direct() error {
-	if tryErr := d.tryAll(); tryErr != nil {
-		if cancelErr := d.cancelAll(); cancelErr != nil {
+	return d.DirectContext(context.Background())
+}
+
+func (d *director) DirectContext(ctx context.Context) error {
+	return d.measure(func() error { return d.direct(ctx) })
+}
+
+func (d *director) direct(ctx context.Context) error {
+	tryCtx, cancelTry := context.WithCancel(ctx)
+	defer cancelTry()
+	if err := d.begin(ctx, cancelTry); err != nil {
+		d.setStatus(StatusCanceled)
+		return err
+	}
+	d.emit(StatusTrying)
+	if err := d.admit(); err != nil {
+		d.setStatus(StatusCanceled)
+		return err
+	}
+	if err := d.validateAll(tryCtx); err != nil {
+		d.setStatus(StatusCanceled)
+		return err
+	}
+	if err := d.lockResources(tryCtx); err != nil {
+		d.setStatus(StatusCanceled)
+		return err
+	}
+	// a failed transaction keeps its resources until it is resumed to Confirmed or Canceled,
+	// or until they expire because they are no longer renewed
+	defer func() {
+		if d.Status() != StatusFailed {
+			d.unlockResources()
+		}
+		d.stopRenewal()
+	}()
+	d.startedAt = time.Now()
+	if err := d.save(ctx, StatusTrying); err != nil {
+		d.setStatus(StatusCanceled)
+		return &Error{
+			failedPhase: ErrTryFailed,
+			err:         &CoordinatorError{op: "save transaction state", err: err},
+		}
+	}
+	if tryErr := d.enterConfirm(ctx, d.tryAll(tryCtx)); tryErr != nil {
+		d.tryErr = tryErr
+		d.setStatus(StatusCanceling)
+		cancelCtx := detachedContext{ctx}
+		// if saving fails, the transaction stays Trying, which is canceled on recovery too
+		_ = d.save(cancelCtx, StatusCanceling)
+		if cancelErr := d.cancelAll(cancelCtx); cancelErr != nil {
+			d.setStatus(StatusFailed)
 			return cancelErr
 		}
+		d.setStatus(StatusCanceled)
+		_ = d.save(cancelCtx, StatusCanceled)
 		return tryErr
 	}
-	return d.confirmAll()
+	d.emit(StatusConfirming)
+	if err := d.confirmAll(ctx); err != nil {
+		d.setStatus(StatusFailed)
+		return err
+	}
+	d.setStatus(StatusConfirmed)
+	if err := d.save(ctx, StatusConfirmed); err != nil {
+		return &CoordinatorError{op: "save transaction state, it will be confirmed again on recovery", err: err}
+	}
+	d.complete()
+	return nil
+}
+
+// detachedContext keeps values of the parent context, but is never done.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (c detachedContext) Done() <-chan struct{}             { return nil }
+func (c detachedContext) Err() error                        { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+func (d *director) complete() {
+	if d.onComplete == nil {
+		return
+	}
+	d.completed.Do(func() { d.onComplete(d.txId) })
 }
 
-func (d *director) tryAll() error {
-	eg := errgroup.Group{}
+func (d *director) admit() error {
+	if d.health == nil {
+		return nil
+	}
 	for _, s := range d.services {
-		s := s
-		eg.Go(func() error {
-			s.tried = true
-			err := s.Try()
-			if err != nil {
-				return &Error{
-					failedPhase: ErrTryFailed,
-					err:         err,
-					serviceName: s.name,
-				}
+		if err := d.health.check(s.name); err != nil {
+			return &Error{
+				failedPhase: ErrTryFailed,
+				err:         err,
+				serviceName: s.name,
 			}
-			s.trySucceeded = true
-			return nil
-		})
+		}
 	}
-	return eg.Wait()
+	return nil
 }
 
-func (d *director) confirmAll() error {
-	eg := errgroup.Group{}
-	for _, s := range d.services {
-		s := s
-		eg.Go(func() error {
-			s.confirmed = true
-			if !s.trySucceeded {
-				return &Error{
-					failedPhase: ErrConfirmFailed,
-					err:         errors.New("try did not succeed"),
-					serviceName: s.name,
-				}
+// retry retries op of the phase with the service's backoff or else director's backoff,
+// every attempt waits until the service's dependency is up.
+func (d *director) retry(ctx context.Context, s *Service, phase Phase, op PhaseFunc) error {
+	newPolicy := s.backoff
+	if newPolicy == nil {
+		newPolicy = d.backoff
+	}
+	return d.retryWith(ctx, s, phase, op, newPolicy())
+}
+
+// retryWith retries op of the phase with policy.
+func (d *director) retryWith(ctx context.Context, s *Service, phase Phase, op PhaseFunc, policy backoff.BackOff) error {
+	action := RecoveryManual
+	if d.store != nil && phase != PhaseTry {
+		action = RecoveryScheduled
+	}
+	attempts := 0
+	return retry(ctx, func() error {
+		if attempts++; attempts > 1 {
+			if d.metrics != nil {
+				d.metrics.Retried(s.name, phase)
 			}
-			d.Lock()
-			defer d.Unlock()
-			err := backoff.Retry(s.Confirm, d.backoff)
-			if err != nil {
-				return &Error{
-					failedPhase: ErrConfirmFailed,
-					err:         err,
-					serviceName: s.name,
-				}
+			d.logInfo("phase retried", "service", s.name, "phase", phase, "attempt", attempts)
+		}
+		if d.health != nil {
+			if err := d.health.wait(ctx, s.name); err != nil {
+				return err
 			}
-			s.confirmSucceeded = true
-			return nil
-		})
+		}
+		return d.call(ctx, s, phase, op)
+	}, policy, d.retryable, action)
+}
+
+// call calls f of the service in the phase, counting it against the limit of WithMaxCalls,
+// wrapped by middlewares and reported to Metrics and Logger.
+func (d *director) call(ctx context.Context, s *Service, phase Phase, f PhaseFunc) error {
+	if d.maxCalls > 0 && atomic.AddInt64(&d.calls, 1) > d.maxCalls {
+		return WithCode(&CoordinatorError{op: "call " + string(phase) + " of " + s.name, err: ErrCallLimitExceeded}, CodePermanent)
+	}
+	d.logInfo("phase started", "service", s.name, "phase", phase)
+	start := time.Now()
+	ctx = context.WithValue(ctx, callInfoKey{}, CallInfo{TxId: d.txId, ServiceName: s.name, Phase: phase, Isolation: s.isolation})
+	err := s.callWithTimeout(ctx, phase, recoverPanic(d.wrap(f)))
+	elapsed := time.Since(start)
+	if d.metrics != nil {
+		d.metrics.PhaseDone(s.name, phase, err, elapsed)
+	}
+	if err != nil {
+		d.logError("phase failed", "service", s.name, "phase", phase, "elapsed", elapsed, "error", err)
+	} else {
+		d.logInfo("phase finished", "service", s.name, "phase", phase, "elapsed", elapsed)
 	}
-	return eg.Wait()
+	return err
 }
 
-func (d *director) cancelAll() error {
-	eg := errgroup.Group{}
+// each calls f for every service, and returns the error, or *MultiError if f failed for several services.
+// Services are called concurrently, or one by one in the order decided by the seed
+// given to WithDeterministicSchedule.
+func (d *director) each(f func(s *Service) error) error {
+	errs := make([]error, len(d.services))
+	if d.schedule != nil {
+		for _, i := range d.schedule.Perm(len(d.services)) {
+			errs[i] = f(d.services[i])
+		}
+		return multiError(errs)
+	}
+	var wg sync.WaitGroup
+	for i, s := range d.services {
+		i, s := i, s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = f(s)
+		}()
+	}
+	wg.Wait()
+	return multiError(errs)
+}
+
+func (d *director) validateAll(ctx context.Context) error {
+	validating := false
 	for _, s := range d.services {
-		s := s
-		eg.Go(func() error {
-			if !s.trySucceeded {
-				return nil
+		if s.validate != nil {
+			validating = true
+		}
+	}
+	if !validating {
+		return nil
+	}
+	return d.each(func(s *Service) error {
+		if err := d.call(ctx, s, PhaseValidate, s.ValidateContext); err != nil {
+			return &Error{
+				failedPhase: failedPhase(ErrValidateFailed, err),
+				err:         err,
+				serviceName: s.name,
+			}
+		}
+		return nil
+	})
+}
+
+func (d *director) tryAll(ctx context.Context) error {
+	return d.each(func(s *Service) error {
+		d.update(func() { s.tried = true })
+		var err error
+		if d.tryRetries > 0 {
+			err = d.retryWith(ctx, s, PhaseTry, s.TryContext, backoff.WithMaxRetries(d.delays(), d.tryRetries))
+		} else {
+			err = d.call(ctx, s, PhaseTry, s.TryContext)
+		}
+		if err != nil {
+			return &Error{
+				failedPhase: failedPhase(ErrTryFailed, err),
+				err:         err,
+				serviceName: s.name,
+			}
+		}
+		d.update(func() { s.trySucceeded = true })
+		d.markDone(ctx, s, PhaseTry)
+		return nil
+	})
+}
+
+func (d *director) confirmAll(ctx context.Context) error {
+	return d.each(func(s *Service) error {
+		if s.confirmSucceeded {
+			return nil
+		}
+		d.update(func() { s.confirmed = true })
+		if !s.trySucceeded {
+			return &Error{
+				failedPhase: ErrConfirmFailed,
+				err:         errors.New("try did not succeed"),
+				serviceName: s.name,
 			}
-			s.canceled = true
-			d.Lock()
-			defer d.Unlock()
-			err := backoff.Retry(s.Cancel, d.backoff)
-			if err != nil {
-				return &Error{
-					failedPhase: ErrCancelFailed,
-					err:         err,
-					serviceName: s.name,
-				}
+		}
+		err := d.retry(ctx, s, PhaseConfirm, s.ConfirmContext)
+		if err != nil {
+			return &Error{
+				failedPhase: failedPhase(ErrConfirmFailed, err),
+				err:         err,
+				serviceName: s.name,
+			}
+		}
+		d.update(func() { s.confirmSucceeded = true })
+		d.markDone(ctx, s, PhaseConfirm)
+		return nil
+	})
+}
+
+func (d *director) cancelAll(ctx context.Context) error {
+	return d.each(func(s *Service) error {
+		if !(s.trySucceeded || s.lateTry) || s.cancelSucceeded {
+			return nil
+		}
+		d.update(func() { s.canceled = true })
+		err := d.retry(ctx, s, PhaseCancel, func(ctx context.Context) error {
+			if err := s.CancelContext(ctx); CodeOf(err) != CodeNotFound {
+				return err
 			}
-			s.cancelSucceeded = true
 			return nil
 		})
-	}
-	return eg.Wait()
+		if err != nil {
+			return &Error{
+				failedPhase: failedPhase(ErrCancelFailed, err),
+				err:         err,
+				serviceName: s.name,
+			}
+		}
+		d.update(func() { s.cancelSucceeded = true })
+		d.markDone(ctx, s, PhaseCancel)
+		return nil
+	})
 }
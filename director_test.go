@@ -1,8 +1,12 @@
 package tcc
 
 import (
+	"context"
 	"errors"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cenkalti/backoff/v3"
 )
@@ -10,7 +14,7 @@ import (
 func Test_director_Direct_No_Error(t *testing.T) {
 	type fields struct {
 		services []*Service
-		backoff  backoff.BackOff
+		backoff  func() backoff.BackOff
 	}
 	tests := []struct {
 		name    string
@@ -46,7 +50,7 @@ func Test_director_Direct_No_Error(t *testing.T) {
 						},
 					),
 				},
-				backoff: backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 1),
+				backoff: func() backoff.BackOff { return backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 1) },
 			},
 			wantErr: false,
 		},
@@ -153,7 +157,7 @@ func Test_director_Direct_Error(t *testing.T) {
 							return nil
 						},
 						func() error { // cancel
-							return nil
+							return errors.New("test")
 						},
 					),
 					NewService(
@@ -197,3 +201,377 @@ func Test_director_Direct_Error(t *testing.T) {
 		})
 	}
 }
+
+func Test_director_Direct_DeterministicSchedule(t *testing.T) {
+	run := func(seed int64) []string {
+		var called []string
+		var services []*Service
+		for _, name := range []string{"s1", "s2", "s3", "s4", "s5"} {
+			name := name
+			services = append(services, NewService(
+				name,
+				func() error { called = append(called, "try "+name); return nil },
+				func() error { called = append(called, "confirm "+name); return nil },
+				func() error { return nil },
+			))
+		}
+		if err := NewDirector(services, WithDeterministicSchedule(seed)).Direct(); err != nil {
+			t.Fatalf("director.Direct() error = %v", err)
+		}
+		return called
+	}
+
+	first := run(1)
+	if len(first) != 10 {
+		t.Fatalf("called = %v, want 10 calls", first)
+	}
+	for i := 0; i < 5; i++ {
+		if got := run(1); !reflect.DeepEqual(got, first) {
+			t.Errorf("order with the same seed = %v, want %v", got, first)
+		}
+	}
+	var differs bool
+	for seed := int64(2); seed < 10; seed++ {
+		if !reflect.DeepEqual(run(seed), first) {
+			differs = true
+		}
+	}
+	if !differs {
+		t.Errorf("order does not depend on the seed")
+	}
+}
+
+func TestWithMaxRetries_Zero(t *testing.T) {
+	var attempts int
+	d := NewDirector([]*Service{
+		NewService(
+			"s1",
+			func() error { return nil },
+			func() error { attempts++; return errors.New("test") },
+			func() error { return nil },
+		),
+	}, WithMaxRetries(0))
+	if err := d.Direct(); err == nil {
+		t.Errorf("director.Direct() error = nil, want error")
+	}
+	if attempts != 1 {
+		t.Errorf("confirm() called %v times, want 1", attempts)
+	}
+}
+
+func Test_director_Direct_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		validateErr error
+		wantTried   bool
+		wantErr     bool
+	}{
+		{
+			name:      "validated",
+			wantTried: true,
+			wantErr:   false,
+		},
+		{
+			name:        "validate failed",
+			validateErr: errors.New("test"),
+			wantTried:   false,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried1, tried2 bool
+			d := NewDirector([]*Service{
+				NewService(
+					"s1",
+					func() error { tried1 = true; return nil },
+					func() error { return nil },
+					func() error { return nil },
+				),
+				NewService(
+					"s2",
+					func() error { tried2 = true; return nil },
+					func() error { return nil },
+					func() error { return nil },
+					WithValidate(func() error { return tt.validateErr }),
+				),
+			})
+			err := d.Direct()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("director.Direct() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tried1 != tt.wantTried || tried2 != tt.wantTried {
+				t.Errorf("tried = %v, %v, want %v", tried1, tried2, tt.wantTried)
+			}
+			if err == nil {
+				return
+			}
+			e, ok := err.(*Error)
+			if !ok || e.FailedPhase() != ErrValidateFailed || e.ServiceName() != "s2" {
+				t.Errorf("director.Direct() error = %v, want validate failure of s2", err)
+			}
+		})
+	}
+}
+
+func Test_director_Direct_OnComplete(t *testing.T) {
+	tests := []struct {
+		name          string
+		tryErr        error
+		confirmErrs   int
+		resume        bool
+		wantCompleted int
+	}{
+		{
+			name:          "confirmed",
+			wantCompleted: 1,
+		},
+		{
+			name:          "canceled",
+			tryErr:        errors.New("test"),
+			wantCompleted: 0,
+		},
+		{
+			name:          "confirm failed",
+			confirmErrs:   1,
+			wantCompleted: 0,
+		},
+		{
+			name:          "confirmed by resume",
+			confirmErrs:   1,
+			resume:        true,
+			wantCompleted: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var completed, confirms int
+			var gotTxId string
+			tx := NewTransaction([]*Service{
+				NewService(
+					"s1",
+					func() error { return tt.tryErr },
+					func() error {
+						confirms++
+						if confirms <= tt.confirmErrs {
+							return errors.New("test")
+						}
+						return nil
+					},
+					func() error { return nil },
+				),
+			}, WithMaxRetries(0), WithOnComplete(func(txId string) { completed++; gotTxId = txId }))
+			_ = tx.Wait()
+			if tt.resume {
+				_ = tx.Resume()
+				_ = tx.Resume()
+			}
+			if completed != tt.wantCompleted {
+				t.Errorf("OnComplete called %v times, want %v", completed, tt.wantCompleted)
+			}
+			if completed > 0 && gotTxId != tx.(*director).txId {
+				t.Errorf("OnComplete got txId %v, want %v", gotTxId, tx.(*director).txId)
+			}
+		})
+	}
+}
+
+func Test_director_DirectContext(t *testing.T) {
+	type key struct{}
+
+	t.Run("hung try is canceled by deadline", func(t *testing.T) {
+		var canceled bool
+		d := NewDirector([]*Service{
+			NewServiceContext(
+				"s1",
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { canceled = true; return nil },
+			),
+			NewServiceContext(
+				"s2",
+				func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return nil },
+			),
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := d.DirectContext(ctx)
+		e, ok := err.(*Error)
+		if !ok || e.FailedPhase() != ErrTryFailed || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("director.DirectContext() error = %v, want try failure by deadline", err)
+		}
+		if !canceled {
+			t.Errorf("cancel() is not called")
+		}
+	})
+
+	t.Run("cancel after deadline honors context", func(t *testing.T) {
+		var cancelValue interface{}
+		d := newDirector([]*Service{
+			NewServiceContext(
+				"s1",
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error {
+					if err := ctx.Err(); err != nil {
+						return err
+					}
+					cancelValue = ctx.Value(key{})
+					return nil
+				},
+			),
+			NewServiceContext(
+				"s2",
+				func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return nil },
+			),
+		})
+		ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), key{}, "v"), 20*time.Millisecond)
+		defer cancel()
+		if err := d.DirectContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("director.DirectContext() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if got := d.Status(); got != StatusCanceled {
+			t.Errorf("director.Status() = %v, want %v", got, StatusCanceled)
+		}
+		if cancelValue != "v" {
+			t.Errorf("cancel got value %v, want values of the context", cancelValue)
+		}
+	})
+
+	t.Run("done context", func(t *testing.T) {
+		var tried bool
+		d := NewDirector([]*Service{
+			NewService(
+				"s1",
+				func() error { tried = true; return nil },
+				func() error { return nil },
+				func() error { return nil },
+			),
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := d.DirectContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("director.DirectContext() error = %v, want %v", err, context.Canceled)
+		}
+		if tried {
+			t.Errorf("try() is called")
+		}
+	})
+
+	t.Run("context is passed to every phase", func(t *testing.T) {
+		var got []interface{}
+		phase := func(ctx context.Context) error { got = append(got, ctx.Value(key{})); return nil }
+		d := NewDirector([]*Service{
+			NewServiceContext("s1", phase, phase, phase, WithValidateContext(phase)),
+		})
+		if err := d.DirectContext(context.WithValue(context.Background(), key{}, "v")); err != nil {
+			t.Errorf("director.DirectContext() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, []interface{}{"v", "v", "v"}) {
+			t.Errorf("context values = %v, want value in validate, try and confirm", got)
+		}
+	})
+}
+
+func Test_director_Direct_ConcurrentConfirm(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	inConfirm := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(inConfirm)
+	}()
+	confirm := func() error {
+		wg.Done()
+		// first attempt fails until every service is confirming at the same time
+		select {
+		case <-inConfirm:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("confirms are serialized")
+		}
+	}
+	d := NewDirector([]*Service{
+		NewService("s1", func() error { return nil }, confirm, func() error { return nil }),
+		NewService("s2", func() error { return nil }, confirm, func() error { return nil }),
+	}, WithMaxRetries(0))
+	if err := d.Direct(); err != nil {
+		t.Errorf("director.Direct() error = %v", err)
+	}
+}
+
+func TestWithTryRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		tryErr     error
+		wantCalls  int
+		wantStatus Status
+	}{
+		{
+			name:       "not retried by default",
+			tryErr:     errors.New("test"),
+			wantCalls:  1,
+			wantStatus: StatusCanceled,
+		},
+		{
+			name:       "retried",
+			opts:       []Option{WithTryRetries(2)},
+			tryErr:     errors.New("test"),
+			wantCalls:  2,
+			wantStatus: StatusConfirmed,
+		},
+		{
+			name:       "permanent",
+			opts:       []Option{WithTryRetries(2)},
+			tryErr:     WithCode(errors.New("test"), CodePermanent),
+			wantCalls:  1,
+			wantStatus: StatusCanceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			tx := NewTransaction([]*Service{
+				NewService("s1", func() error {
+					// the first try fails
+					if calls++; calls == 1 {
+						return tt.tryErr
+					}
+					return nil
+				}, func() error { return nil }, func() error { return nil }),
+			}, append(tt.opts, WithDecorrelatedJitter(time.Millisecond, time.Millisecond))...)
+			_ = tx.Wait()
+			if calls != tt.wantCalls {
+				t.Errorf("try called %v times, want %v", calls, tt.wantCalls)
+			}
+			if got := tx.Status(); got != tt.wantStatus {
+				t.Errorf("Transaction.Status() = %v, want %v", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWithTxId(t *testing.T) {
+	var got string
+	s := NewServiceContext("s1", func(ctx context.Context) error {
+		info, _ := CallInfoFrom(ctx)
+		got = info.TxId
+		return nil
+	}, func(context.Context) error { return nil }, func(context.Context) error { return nil })
+	d := NewDirector([]*Service{s}, WithTxId("order-1"))
+	if err := d.Direct(); err != nil {
+		t.Fatalf("director.Direct() error = %v", err)
+	}
+	if d.TxId() != "order-1" || got != "order-1" {
+		t.Errorf("director.TxId() = %v, CallInfo.TxId = %v, want %v", d.TxId(), got, "order-1")
+	}
+	if generated := NewDirector([]*Service{s}).TxId(); generated == "" || generated == "order-1" {
+		t.Errorf("director.TxId() = %q, want generated ID", generated)
+	}
+}
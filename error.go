@@ -1,5 +1,10 @@
 package tcc
 
+import (
+	"errors"
+	"strings"
+)
+
 const (
 	// ErrTryFailed means at least 1 error happened in Try phase,
 	// but successfully canceled.
@@ -16,6 +21,15 @@ const (
 	// and attempted to cancel all the services, but some resources could not be canceled.
 	// Basically, you need to fix inconsistent state manually
 	ErrCancelFailed
+
+	// ErrValidateFailed means at least 1 service failed to validate before Try phase,
+	// so nothing is reserved and nothing needs to be canceled.
+	ErrValidateFailed
+
+	// ErrPanicked means a validate, try, confirm or cancel function of a service panicked.
+	// The panic is recovered, and the transaction is canceled like a failed try if it panicked before confirm phase.
+	// Unwrap the error to *PanicError to get the panic value and stack trace.
+	ErrPanicked
 )
 
 // Error knows what err happened in try/confirm/cancel phase.
@@ -35,7 +49,102 @@ func (e *Error) Error() string {
 	return e.err.Error()
 }
 
+// Unwrap returns the error which caused try/confirm/cancel failure.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
 // ServiceName returns the name of service which is failed to try/confirm/cancel.
 func (e *Error) ServiceName() string {
 	return e.serviceName
 }
+
+// CoordinatorError is the cause of errors failed by the director itself rather than by services,
+// e.g. because Store or ResourceLocker is down, or the limit of WithMaxCalls is exceeded,
+// so that callers can fall back differently, e.g. retry the whole transaction later instead of alerting about the service.
+// Find it with errors.As.
+type CoordinatorError struct {
+	op  string
+	err error
+}
+
+// Op returns what the director failed to do, e.g. "save transaction state".
+func (e *CoordinatorError) Op() string {
+	return e.op
+}
+
+// Error satisfies error interface
+func (e *CoordinatorError) Error() string {
+	return e.op + ": " + e.err.Error()
+}
+
+// Unwrap returns the error which caused the failure.
+func (e *CoordinatorError) Unwrap() error {
+	return e.err
+}
+
+// MultiError is returned when several services failed in the same phase,
+// e.g. to tell operators every service which failed to cancel.
+// errors.Is and errors.As look into *Error of each service, and errors.As finds the first one.
+type MultiError struct {
+	errs []error
+}
+
+// Errors returns the error of each failed service in the order of services.
+func (e *MultiError) Errors() []error {
+	return e.errs
+}
+
+// Error satisfies error interface
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the error of each failed service.
+func (e *MultiError) Unwrap() []error {
+	return e.errs
+}
+
+// Is reports whether the error of any failed service matches target.
+// It is there for errors.Is of Go versions which do not walk Unwrap() []error.
+func (e *MultiError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error of the failed services which matches target.
+// It is there for errors.As of Go versions which do not walk Unwrap() []error.
+func (e *MultiError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// multiError returns nil, the only error, or *MultiError of the non-nil errs.
+func multiError(errs []error) error {
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	default:
+		return &MultiError{errs: failed}
+	}
+}
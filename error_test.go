@@ -2,6 +2,7 @@ package tcc
 
 import (
 	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -107,3 +108,112 @@ func TestError_ServiceName(t *testing.T) {
 		})
 	}
 }
+
+func TestError_Unwrap(t *testing.T) {
+	cause := errors.New("test")
+	e := &Error{failedPhase: ErrConfirmFailed, err: &RetryError{errs: []error{cause}}}
+	var retryErr *RetryError
+	if !errors.As(e, &retryErr) {
+		t.Errorf("cannot unwrap to RetryError")
+	}
+	if !errors.Is(e, cause) {
+		t.Errorf("Error does not wrap the last service error")
+	}
+}
+
+func TestMultiError(t *testing.T) {
+	errDown := errors.New("down")
+	failing := func(name string) *Service {
+		return NewService(
+			name,
+			func() error { return nil },
+			func() error { return nil },
+			func() error { return errDown },
+		)
+	}
+	d := NewDirector([]*Service{
+		failing("s1"),
+		NewService("s2", func() error { return errors.New("test") }, func() error { return nil }, func() error { return nil }),
+		failing("s3"),
+	}, WithMaxRetries(0))
+	err := d.Direct()
+	var me *MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("director.Direct() error = %v, want *MultiError", err)
+	}
+	var names []string
+	for _, err := range me.Errors() {
+		var e *Error
+		if !errors.As(err, &e) || e.FailedPhase() != ErrCancelFailed {
+			t.Errorf("MultiError.Errors() has %v, want cancel failure", err)
+			continue
+		}
+		names = append(names, e.ServiceName())
+	}
+	if want := []string{"s1", "s3"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("failed services = %v, want %v", names, want)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.ServiceName() != "s1" {
+		t.Errorf("errors.As() of MultiError = %v, want error of s1", e)
+	}
+	// Is and As do not rely on errors walking Unwrap() []error
+	e = nil
+	if !me.As(&e) || e.ServiceName() != "s1" {
+		t.Errorf("MultiError.As() = %v, want error of s1", e)
+	}
+	if !me.Is(errDown) {
+		t.Errorf("MultiError.Is() = false, want true for error of cancel")
+	}
+	if me.Is(ErrAborted) {
+		t.Errorf("MultiError.Is(ErrAborted) = true")
+	}
+}
+
+func TestCoordinatorError(t *testing.T) {
+	ok := func() error { return nil }
+	tests := []struct {
+		name   string
+		tryErr error
+		opts   []Option
+		wantOp string
+	}{
+		{
+			name:   "store is down",
+			opts:   []Option{WithStore(&failingStore{Store: NewMemoryStore(), status: StatusTrying})},
+			wantOp: "save transaction state",
+		},
+		{
+			name:   "store is down after confirmed",
+			opts:   []Option{WithStore(&failingStore{Store: NewMemoryStore(), status: StatusConfirmed})},
+			wantOp: "save transaction state, it will be confirmed again on recovery",
+		},
+		{
+			name:   "call limit exceeded",
+			opts:   []Option{WithMaxCalls(1)},
+			wantOp: "call confirm of s1",
+		},
+		{
+			name:   "participant failed",
+			tryErr: errors.New("test"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDirector([]*Service{
+				NewService("s1", func() error { return tt.tryErr }, ok, ok),
+			}, append(tt.opts, WithMaxRetries(0))...)
+			err := d.Direct()
+			if err == nil {
+				t.Fatal("director.Direct() error = nil, want error")
+			}
+			var e *CoordinatorError
+			if got := errors.As(err, &e); got != (tt.wantOp != "") {
+				t.Fatalf("errors.As(%v, *CoordinatorError) = %v, want %v", err, got, tt.wantOp != "")
+			}
+			if e != nil && e.Op() != tt.wantOp {
+				t.Errorf("CoordinatorError.Op() = %v, want %v", e.Op(), tt.wantOp)
+			}
+		})
+	}
+}
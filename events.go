@@ -0,0 +1,79 @@
+package tcc
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// EventTypePrefix is the prefix of the type of CloudEvents emitted to EventSink,
+// which is followed by the status of the transaction, e.g. "io.github.dllen.tcc.transaction.confirmed".
+const EventTypePrefix = "io.github.dllen.tcc.transaction."
+
+// CloudEvent is a lifecycle event of a transaction in CloudEvents 1.0 format.
+// It is marshaled to the JSON event format, so that it can be sent to brokers without conversion.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// TxEventData is the data of CloudEvent.
+type TxEventData struct {
+	TxId     string   `json:"txId"`
+	Status   string   `json:"status"`
+	Services []string `json:"services"`
+}
+
+// EventSink receives lifecycle events of transactions, e.g. to publish them to a broker.
+// Send is called synchronously when the status of a transaction changes,
+// so it needs to be safe for concurrent use and fast, like Metrics.
+type EventSink interface {
+	Send(event *CloudEvent)
+}
+
+// WithEventSink sets EventSink which receives an event every time the status of a transaction changes.
+// source is the source attribute of the events, which identifies the coordinator, e.g. "/orders/checkout".
+func WithEventSink(source string, sink EventSink) Option {
+	return func(d *director) {
+		d.eventSource = source
+		d.events = sink
+	}
+}
+
+// emit sends the event of the status to EventSink.
+func (d *director) emit(status Status) {
+	if d.events == nil {
+		return
+	}
+	data := TxEventData{TxId: d.txId, Status: status.String()}
+	for _, s := range d.services {
+		data.Services = append(data.Services, s.name)
+	}
+	// TxEventData always marshals
+	raw, _ := json.Marshal(data)
+	d.events.Send(&CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              xid.New().String(),
+		Source:          d.eventSource,
+		Type:            EventTypePrefix + eventType(status),
+		Subject:         d.txId,
+		Time:            time.Now(),
+		DataContentType: "application/json",
+		Data:            raw,
+	})
+}
+
+// eventType returns the status in CloudEvents type, which does not allow spaces.
+func eventType(status Status) string {
+	if status == StatusNotStarted {
+		return "not_started"
+	}
+	return status.String()
+}
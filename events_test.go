@@ -0,0 +1,85 @@
+package tcc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type recordingSink struct {
+	events []*CloudEvent
+	sync.Mutex
+}
+
+func (s *recordingSink) Send(event *CloudEvent) {
+	s.Lock()
+	defer s.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *recordingSink) types() []string {
+	s.Lock()
+	defer s.Unlock()
+	var types []string
+	for _, e := range s.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestWithEventSink(t *testing.T) {
+	ok := func() error { return nil }
+	tests := []struct {
+		name    string
+		tryErr  error
+		wantErr bool
+		want    []string
+	}{
+		{
+			name: "confirmed",
+			want: []string{
+				EventTypePrefix + "trying",
+				EventTypePrefix + "confirming",
+				EventTypePrefix + "confirmed",
+			},
+		},
+		{
+			name:    "canceled",
+			tryErr:  errors.New("test"),
+			wantErr: true,
+			want: []string{
+				EventTypePrefix + "trying",
+				EventTypePrefix + "canceling",
+				EventTypePrefix + "canceled",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			d := newDirector([]*Service{
+				NewService("s1", func() error { return tt.tryErr }, ok, ok),
+			}, WithEventSink("/test", sink))
+			if err := d.Direct(); (err != nil) != tt.wantErr {
+				t.Fatalf("director.Direct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := sink.types(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("event types = %v, want %v", got, tt.want)
+			}
+			e := sink.events[len(sink.events)-1]
+			if e.SpecVersion != "1.0" || e.Source != "/test" || e.Subject != d.txId || e.ID == "" {
+				t.Errorf("event = %+v", e)
+			}
+			var data TxEventData
+			if err := json.Unmarshal(e.Data, &data); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+			want := TxEventData{TxId: d.txId, Status: tt.name, Services: []string{"s1"}}
+			if !reflect.DeepEqual(data, want) {
+				t.Errorf("event data = %+v, want %+v", data, want)
+			}
+		})
+	}
+}
@@ -96,12 +96,12 @@ func (db *MockDB) cancelCoupon() error {
 }
 
 func main() {
-	log.Printf("start db storage %v", db.storage)
-	log.Printf("start db coupon %v", db.coupon)
+	log.Printf("start db storage %v", &db.storage)
+	log.Printf("start db coupon %v", &db.coupon)
 	doFirstOrder(db)
 	doSecondOrder(db)
-	log.Printf("end db storage %v", db.storage)
-	log.Printf("end db coupon %v", db.coupon)
+	log.Printf("end db storage %v", &db.storage)
+	log.Printf("end db coupon %v", &db.coupon)
 }
 
 func doFirstOrder(db *MockDB) {
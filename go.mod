@@ -1,10 +1,18 @@
 module github.com/dllen/g-tcc
 
-go 1.13
+go 1.18
 
 require (
-	github.com/cenkalti/backoff v2.2.1+incompatible
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/alicebob/miniredis/v2 v2.23.0
 	github.com/cenkalti/backoff/v3 v3.1.1
+	github.com/go-redis/redis/v8 v8.11.5
 	github.com/rs/xid v1.2.1
-	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/yuin/gopher-lua v0.0.0-20210529063254-f4c35e4016d9 // indirect
 )
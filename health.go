@@ -0,0 +1,175 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrDependencyDown is returned when a transaction is refused
+	// because one of its services is marked down in the HealthRegistry.
+	ErrDependencyDown = errors.New("dependency is marked down")
+
+	// ErrInMaintenance is returned when a transaction is refused
+	// because one of its services is in maintenance.
+	ErrInMaintenance = errors.New("dependency is in maintenance")
+
+	// ErrDraining is returned when a transaction is refused
+	// because one of its services is draining.
+	ErrDraining = errors.New("dependency is draining")
+)
+
+// HealthRegistry keeps health of downstream dependencies keyed by dependency name.
+// It can be shared by multiple directors, and fed by anything which knows
+// the dependency health, e.g. circuit breakers or health probes.
+// By default the dependency name of a service is its name.
+type HealthRegistry struct {
+	deps map[string]*dependency
+
+	sync.RWMutex
+}
+
+type dependency struct {
+	down        bool
+	maintenance bool
+	draining    bool
+
+	// resumed is closed when the dependency becomes available again for retries
+	resumed chan struct{}
+}
+
+// paused returns if retries against the dependency are paused.
+func (dep *dependency) paused() bool {
+	return dep.down || dep.maintenance
+}
+
+// NewHealthRegistry returns empty HealthRegistry, every dependency is up.
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{deps: map[string]*dependency{}}
+}
+
+// MarkDown marks the dependency as down.
+func (r *HealthRegistry) MarkDown(name string) {
+	r.update(name, func(dep *dependency) { dep.down = true })
+}
+
+// MarkUp marks the dependency as up, and resumes paused retries against it.
+func (r *HealthRegistry) MarkUp(name string) {
+	r.update(name, func(dep *dependency) { dep.down = false })
+}
+
+// StartMaintenance flags the dependency as in maintenance.
+// While flagged, new transactions including it are refused and retries against it are paused.
+func (r *HealthRegistry) StartMaintenance(name string) {
+	r.update(name, func(dep *dependency) { dep.maintenance = true })
+}
+
+// EndMaintenance clears the maintenance flag of the dependency.
+func (r *HealthRegistry) EndMaintenance(name string) {
+	r.update(name, func(dep *dependency) { dep.maintenance = false })
+}
+
+// StartDraining flags the dependency as draining, e.g. when its instance signals it is shutting down
+// in a rolling restart. While flagged, new transactions including it are refused,
+// but confirm and cancel of the transactions already tried against it continue.
+func (r *HealthRegistry) StartDraining(name string) {
+	r.update(name, func(dep *dependency) { dep.draining = true })
+}
+
+// EndDraining clears the draining flag of the dependency.
+func (r *HealthRegistry) EndDraining(name string) {
+	r.update(name, func(dep *dependency) { dep.draining = false })
+}
+
+// IsDown returns if the dependency is marked down.
+func (r *HealthRegistry) IsDown(name string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	dep, ok := r.deps[name]
+	return ok && dep.down
+}
+
+// InMaintenance returns if the dependency is in maintenance.
+func (r *HealthRegistry) InMaintenance(name string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	dep, ok := r.deps[name]
+	return ok && dep.maintenance
+}
+
+// IsDraining returns if the dependency is draining.
+func (r *HealthRegistry) IsDraining(name string) bool {
+	r.RLock()
+	defer r.RUnlock()
+	dep, ok := r.deps[name]
+	return ok && dep.draining
+}
+
+func (r *HealthRegistry) update(name string, f func(dep *dependency)) {
+	r.Lock()
+	defer r.Unlock()
+	dep, ok := r.deps[name]
+	if !ok {
+		dep = &dependency{resumed: make(chan struct{})}
+		close(dep.resumed)
+		r.deps[name] = dep
+	}
+	wasPaused := dep.paused()
+	f(dep)
+	switch {
+	case wasPaused && !dep.paused():
+		close(dep.resumed)
+	case !wasPaused && dep.paused():
+		dep.resumed = make(chan struct{})
+	}
+	if !dep.paused() && !dep.draining {
+		delete(r.deps, name)
+	}
+}
+
+// check returns error if the dependency is not available.
+func (r *HealthRegistry) check(name string) error {
+	r.RLock()
+	defer r.RUnlock()
+	dep, ok := r.deps[name]
+	switch {
+	case !ok:
+		return nil
+	case dep.maintenance:
+		return ErrInMaintenance
+	case dep.down:
+		return ErrDependencyDown
+	default:
+		return ErrDraining
+	}
+}
+
+// wait blocks until the dependency is available, or returns error when ctx is done.
+func (r *HealthRegistry) wait(ctx context.Context, name string) error {
+	r.RLock()
+	dep, ok := r.deps[name]
+	var resumed chan struct{}
+	if ok {
+		resumed = dep.resumed
+	}
+	r.RUnlock()
+	if !ok {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WithHealthRegistry sets HealthRegistry consulted by director.
+// Transactions including a service marked down, in maintenance or draining are refused before try phase,
+// and confirm/cancel retries against a service marked down or in maintenance are paused until it is available.
+func WithHealthRegistry(r *HealthRegistry) Option {
+	return func(d *director) {
+		d.health = r
+	}
+}
@@ -0,0 +1,197 @@
+package tcc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHealthRegistry_MarkDown(t *testing.T) {
+	tests := []struct {
+		name     string
+		down     []string
+		up       []string
+		target   string
+		wantDown bool
+	}{
+		{
+			name:     "no mark",
+			target:   "s1",
+			wantDown: false,
+		},
+		{
+			name:     "marked down",
+			down:     []string{"s1"},
+			target:   "s1",
+			wantDown: true,
+		},
+		{
+			name:     "marked down twice and up",
+			down:     []string{"s1", "s1"},
+			up:       []string{"s1"},
+			target:   "s1",
+			wantDown: false,
+		},
+		{
+			name:     "other dependency marked down",
+			down:     []string{"s2"},
+			target:   "s1",
+			wantDown: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewHealthRegistry()
+			for _, name := range tt.down {
+				r.MarkDown(name)
+			}
+			for _, name := range tt.up {
+				r.MarkUp(name)
+			}
+			if got := r.IsDown(tt.target); got != tt.wantDown {
+				t.Errorf("HealthRegistry.IsDown() = %v, want %v", got, tt.wantDown)
+			}
+		})
+	}
+}
+
+func TestHealthRegistry_StartMaintenance(t *testing.T) {
+	tests := []struct {
+		name            string
+		down            bool
+		endMaintenance  bool
+		wantMaintenance bool
+		wantErr         error
+	}{
+		{
+			name:            "in maintenance",
+			wantMaintenance: true,
+			wantErr:         ErrInMaintenance,
+		},
+		{
+			name:            "maintenance ended",
+			endMaintenance:  true,
+			wantMaintenance: false,
+			wantErr:         nil,
+		},
+		{
+			name:            "maintenance ended but still down",
+			down:            true,
+			endMaintenance:  true,
+			wantMaintenance: false,
+			wantErr:         ErrDependencyDown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewHealthRegistry()
+			r.StartMaintenance("s1")
+			if tt.down {
+				r.MarkDown("s1")
+			}
+			if tt.endMaintenance {
+				r.EndMaintenance("s1")
+			}
+			if got := r.InMaintenance("s1"); got != tt.wantMaintenance {
+				t.Errorf("HealthRegistry.InMaintenance() = %v, want %v", got, tt.wantMaintenance)
+			}
+			if err := r.check("s1"); err != tt.wantErr {
+				t.Errorf("HealthRegistry.check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_director_Direct_HealthRegistry(t *testing.T) {
+	t.Run("refused when dependency is down", func(t *testing.T) {
+		var tried bool
+		r := NewHealthRegistry()
+		r.MarkDown("s1")
+		d := NewDirector([]*Service{
+			NewService(
+				"s1",
+				func() error { tried = true; return nil },
+				func() error { return nil },
+				func() error { return nil },
+			),
+		}, WithHealthRegistry(r))
+		err := d.Direct()
+		e, ok := err.(*Error)
+		if !ok {
+			t.Errorf("cannot cast to Error")
+			return
+		}
+		if e.FailedPhase() != ErrTryFailed || e.err != ErrDependencyDown {
+			t.Errorf("director.Direct() error = %v, want %v", err, ErrDependencyDown)
+		}
+		if tried {
+			t.Errorf("try() is called")
+		}
+	})
+
+	t.Run("confirm paused until dependency is up", func(t *testing.T) {
+		r := NewHealthRegistry()
+		confirmed := make(chan struct{})
+		d := NewDirector([]*Service{
+			NewService(
+				"s1",
+				func() error { r.MarkDown("s1"); return nil },
+				func() error { close(confirmed); return nil },
+				func() error { return nil },
+			),
+		}, WithHealthRegistry(r), WithMaxRetries(1))
+		done := make(chan error)
+		go func() { done <- d.Direct() }()
+		select {
+		case <-confirmed:
+			t.Fatalf("confirm() is called while dependency is down")
+		case <-time.After(50 * time.Millisecond):
+		}
+		r.MarkUp("s1")
+		if err := <-done; err != nil {
+			t.Errorf("director.Direct() error = %v", err)
+		}
+	})
+}
+
+func TestHealthRegistry_wait_Canceled(t *testing.T) {
+	r := NewHealthRegistry()
+	r.MarkDown("s1")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.wait(ctx, "s1"); err != context.Canceled {
+		t.Errorf("HealthRegistry.wait() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestHealthRegistry_StartDraining(t *testing.T) {
+	r := NewHealthRegistry()
+	r.StartDraining("s1")
+	if !r.IsDraining("s1") {
+		t.Errorf("HealthRegistry.IsDraining() = false, want true")
+	}
+	if err := r.check("s1"); err != ErrDraining {
+		t.Errorf("HealthRegistry.check() error = %v, want %v", err, ErrDraining)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.wait(ctx, "s1"); err != nil {
+		t.Errorf("HealthRegistry.wait() of draining dependency error = %v", err)
+	}
+
+	r.MarkDown("s1")
+	waited := make(chan error, 1)
+	go func() { waited <- r.wait(ctx, "s1") }()
+	r.MarkUp("s1")
+	if err := <-waited; err != nil {
+		t.Errorf("HealthRegistry.wait() after MarkUp() error = %v", err)
+	}
+	if err := r.check("s1"); err != ErrDraining {
+		t.Errorf("HealthRegistry.check() after MarkUp() error = %v, want %v", err, ErrDraining)
+	}
+
+	r.EndDraining("s1")
+	if r.IsDraining("s1") || r.check("s1") != nil {
+		t.Errorf("dependency is not available after EndDraining()")
+	}
+}
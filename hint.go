@@ -0,0 +1,112 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Hint is a guess of why a service failed a transaction, made from the attempts of the service,
+// e.g. "confirm of payment failed after 5 attempts, timed out on every attempt, dependency is marked down".
+type Hint struct {
+	// Service is the name of the service.
+	Service string
+
+	// FailedPhase is the failed phase code of the service, e.g. ErrConfirmFailed.
+	FailedPhase int
+
+	// Attempts is how many times the service was called, 1 if it was not retried.
+	Attempts int
+
+	// TimedOut reports if every attempt kept in RetryError, or the only attempt, timed out.
+	TimedOut bool
+
+	// Dependency is the health of the service in HealthRegistry when the transaction finished,
+	// e.g. ErrDependencyDown when its breaker opened, or nil if it is up or there is no HealthRegistry.
+	Dependency error
+
+	// Message is the hint in words.
+	Message string
+}
+
+// hints returns Hint of every service which failed with err.
+func (d *director) hints(err error) []Hint {
+	var hints []Hint
+	for _, e := range serviceErrors(err) {
+		hints = append(hints, d.hint(e))
+	}
+	return hints
+}
+
+// serviceErrors returns *Error of every service which failed with err.
+func serviceErrors(err error) []*Error {
+	var errs []error
+	var me *MultiError
+	if errors.As(err, &me) {
+		errs = me.Errors()
+	} else {
+		errs = []error{err}
+	}
+	var serviceErrs []*Error
+	for _, err := range errs {
+		var e *Error
+		if !errors.As(err, &e) || e.serviceName == "" {
+			continue
+		}
+		serviceErrs = append(serviceErrs, e)
+	}
+	return serviceErrs
+}
+
+func (d *director) hint(e *Error) Hint {
+	h := Hint{Service: e.serviceName, FailedPhase: e.failedPhase, Attempts: 1}
+	attemptErrs := []error{e.err}
+	var re *RetryError
+	if errors.As(e.err, &re) {
+		h.Attempts = re.Attempts()
+		attemptErrs = re.Errors()
+	}
+	h.TimedOut = len(attemptErrs) > 0
+	for _, err := range attemptErrs {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			h.TimedOut = false
+		}
+	}
+	if d.health != nil {
+		h.Dependency = d.health.check(e.serviceName)
+	}
+
+	msg := []string{fmt.Sprintf("%s of %s failed", phaseName(e.failedPhase), e.serviceName)}
+	if h.Attempts > 1 {
+		msg[0] += fmt.Sprintf(" after %d attempts", h.Attempts)
+	}
+	switch {
+	case h.TimedOut && h.Attempts > 1:
+		msg = append(msg, "timed out on every attempt")
+	case h.TimedOut:
+		msg = append(msg, "timed out")
+	}
+	if h.Dependency != nil {
+		msg = append(msg, h.Dependency.Error())
+	}
+	h.Message = strings.Join(msg, ", ")
+	return h
+}
+
+func phaseName(failedPhase int) string {
+	switch failedPhase {
+	case ErrTryFailed:
+		return "try"
+	case ErrConfirmFailed:
+		return "confirm"
+	case ErrCancelFailed:
+		return "cancel"
+	case ErrValidateFailed:
+		return "validate"
+	case ErrPanicked:
+		return "call"
+	default:
+		return fmt.Sprintf("phase %d", failedPhase)
+	}
+}
@@ -0,0 +1,194 @@
+// Package httpservice provides tcc.Service calling HTTP endpoints of a participant.
+package httpservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dllen/g-tcc"
+)
+
+const (
+	// TxIdHeader is the header carrying ID of the transaction, which participants can use as idempotency key.
+	TxIdHeader = "X-Tcc-Tx-Id"
+
+	// PhaseHeader is the header carrying the phase of the call, e.g. "confirm".
+	PhaseHeader = "X-Tcc-Phase"
+
+	// IsolationHeader is the header carrying the isolation hint of the service set by tcc.WithIsolation, e.g. "lock".
+	// It is not sent if the hint is tcc.IsolationDefault.
+	IsolationHeader = "X-Tcc-Isolation"
+
+	// maxResponseSize is the size of response bodies read at most, so that a broken participant cannot exhaust memory.
+	maxResponseSize = 1 << 20
+)
+
+// Option can set option to the service.
+type Option func(c *caller)
+
+// WithClient sets http.Client used to call the participant, http.DefaultClient by default.
+func WithClient(client *http.Client) Option {
+	return func(c *caller) {
+		c.client = client
+	}
+}
+
+// WithHeader adds header sent with every call, e.g. Content-Type or Authorization.
+func WithHeader(key, value string) Option {
+	return func(c *caller) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithBody sets function returning request body of the phase.
+// tryResult is nil in try phase. By default try sends no body,
+// and confirm and cancel send the response body of try.
+func WithBody(body func(phase tcc.Phase, tryResult []byte) ([]byte, error)) Option {
+	return func(c *caller) {
+		c.body = body
+	}
+}
+
+// WithServiceOptions sets tcc.ServiceOption of the service, e.g. tcc.WithConfirmTimeout.
+func WithServiceOptions(opts ...tcc.ServiceOption) Option {
+	return func(c *caller) {
+		c.serviceOpts = append(c.serviceOpts, opts...)
+	}
+}
+
+// WithDTM makes calls compatible with participants written for the TCC protocol of DTM.
+// Query parameters gid, trans_type, branch_id and op identify the branch, so that DTM barriers work with branchId.
+// DTM sends the same payload to every phase, so WithBody is called with nil tryResult in every phase.
+// Responses of DTM are mapped: 409 or FAILURE in the body fails without retrying, and 425 or ONGOING is retried.
+func WithDTM(branchId string) Option {
+	return func(c *caller) {
+		c.dtm = true
+		c.branchId = branchId
+	}
+}
+
+type caller struct {
+	dtm         bool
+	branchId    string
+	client      *http.Client
+	header      http.Header
+	body        func(phase tcc.Phase, tryResult []byte) ([]byte, error)
+	serviceOpts []tcc.ServiceOption
+}
+
+// NewService returns tcc.Service which POSTs to tryURL, confirmURL and cancelURL in each phase.
+// The response body of try is the result of try, which is passed to confirm and cancel.
+// Every call carries TxIdHeader and PhaseHeader, and IsolationHeader if the service has the hint.
+// Error statuses are annotated with tcc.HTTPStatusCode, so that e.g. 404 of cancel is treated as canceled
+// and 4xx are not retried.
+func NewService(name, tryURL, confirmURL, cancelURL string, opts ...Option) *tcc.Service {
+	c := &caller{
+		client: http.DefaultClient,
+		header: http.Header{},
+		body: func(phase tcc.Phase, tryResult []byte) ([]byte, error) {
+			return tryResult, nil
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return tcc.NewServiceWithResult(
+		name,
+		func(ctx context.Context) ([]byte, error) {
+			return c.call(ctx, tcc.PhaseTry, tryURL, nil)
+		},
+		func(ctx context.Context, tryResult []byte) error {
+			_, err := c.call(ctx, tcc.PhaseConfirm, confirmURL, tryResult)
+			return err
+		},
+		func(ctx context.Context, tryResult []byte) error {
+			_, err := c.call(ctx, tcc.PhaseCancel, cancelURL, tryResult)
+			return err
+		},
+		c.serviceOpts...,
+	)
+}
+
+// call POSTs to url, and returns the response body.
+func (c *caller) call(ctx context.Context, phase tcc.Phase, url string, tryResult []byte) ([]byte, error) {
+	info, _ := tcc.CallInfoFrom(ctx)
+	if c.dtm {
+		tryResult = nil
+		url = dtmURL(url, info.TxId, c.branchId, phase)
+	}
+	body, err := c.body(phase, tryResult)
+	if err != nil {
+		return nil, tcc.Permanent(fmt.Errorf("make %s request body: %w", phase, err))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, tcc.Permanent(err)
+	}
+	for key, values := range c.header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	if info.TxId != "" {
+		req.Header.Set(TxIdHeader, info.TxId)
+	}
+	req.Header.Set(PhaseHeader, string(phase))
+	if info.Isolation != tcc.IsolationDefault {
+		req.Header.Set(IsolationHeader, string(info.Isolation))
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(respBody) > maxResponseSize {
+		return nil, tcc.Permanent(fmt.Errorf("%s %s: response body is larger than %d bytes", phase, url, maxResponseSize))
+	}
+	if c.dtm {
+		if code := dtmCode(resp.StatusCode, respBody); code != tcc.CodeUnknown {
+			return nil, tcc.WithCode(fmt.Errorf("%s %s: %s: %s", phase, url, resp.Status, respBody), code)
+		}
+	}
+	if code := tcc.HTTPStatusCode(resp.StatusCode); code != tcc.CodeUnknown {
+		return nil, tcc.WithCode(fmt.Errorf("%s %s: %s", phase, url, resp.Status), code)
+	}
+	return respBody, nil
+}
+
+// dtmURL adds query parameters of DTM identifying the branch to rawURL.
+func dtmURL(rawURL, gid, branchId string, phase tcc.Phase) string {
+	q := url.Values{}
+	q.Set("gid", gid)
+	q.Set("trans_type", "tcc")
+	q.Set("branch_id", branchId)
+	q.Set("op", string(phase))
+	if strings.Contains(rawURL, "?") {
+		return rawURL + "&" + q.Encode()
+	}
+	return rawURL + "?" + q.Encode()
+}
+
+// dtmCode returns Code of results of DTM, which are also returned as dtm_result of a JSON body with 200.
+func dtmCode(status int, body []byte) tcc.Code {
+	var result struct {
+		DTMResult string `json:"dtm_result"`
+	}
+	// a body which is not JSON has no dtm_result
+	_ = json.Unmarshal(body, &result)
+	switch {
+	case status == http.StatusTooEarly || result.DTMResult == "ONGOING":
+		return tcc.CodeRetryable
+	case status == http.StatusConflict || result.DTMResult == "FAILURE":
+		return tcc.CodeConflict
+	default:
+		return tcc.CodeUnknown
+	}
+}
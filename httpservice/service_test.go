@@ -0,0 +1,240 @@
+package httpservice
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dllen/g-tcc"
+)
+
+type request struct {
+	path, txId, phase, auth, body string
+}
+
+func TestNewService(t *testing.T) {
+	tests := []struct {
+		name       string
+		tryStatus  int
+		wantErr    bool
+		wantStatus tcc.Status
+		wantPaths  []string
+	}{
+		{
+			name:       "confirmed",
+			tryStatus:  http.StatusOK,
+			wantStatus: tcc.StatusConfirmed,
+			wantPaths:  []string{"/try", "/confirm"},
+		},
+		{
+			name:       "canceled",
+			tryStatus:  http.StatusConflict,
+			wantErr:    true,
+			wantStatus: tcc.StatusCanceled,
+			wantPaths:  []string{"/try"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var requests []request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				requests = append(requests, request{r.URL.Path, r.Header.Get(TxIdHeader), r.Header.Get(PhaseHeader), r.Header.Get("Authorization"), string(body)})
+				mu.Unlock()
+				if r.URL.Path == "/try" {
+					w.WriteHeader(tt.tryStatus)
+					_, _ = w.Write([]byte("reservation-1"))
+				}
+			}))
+			defer server.Close()
+
+			s := NewService("s1", server.URL+"/try", server.URL+"/confirm", server.URL+"/cancel",
+				WithClient(server.Client()), WithHeader("Authorization", "Bearer test"))
+			tx := tcc.NewTransaction([]*tcc.Service{s}, tcc.WithTxId("tx1"), tcc.WithMaxRetries(0))
+			if err := tx.Wait(); (err != nil) != tt.wantErr {
+				t.Fatalf("Transaction.Wait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tx.Status(); got != tt.wantStatus {
+				t.Errorf("Transaction.Status() = %v, want %v", got, tt.wantStatus)
+			}
+			var paths []string
+			for _, r := range requests {
+				paths = append(paths, r.path)
+				if r.txId != "tx1" || r.phase != r.path[1:] || r.auth != "Bearer test" {
+					t.Errorf("request = %+v", r)
+				}
+				if r.path == "/confirm" && r.body != "reservation-1" {
+					t.Errorf("confirm body = %q, want the response body of try", r.body)
+				}
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("requested %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestNewService_isolation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []tcc.ServiceOption
+		want string
+	}{
+		{
+			name: "default",
+			want: "",
+		},
+		{
+			name: "lock",
+			opts: []tcc.ServiceOption{tcc.WithIsolation(tcc.IsolationLock)},
+			want: "lock",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				got = append(got, r.Header.Get(IsolationHeader))
+				mu.Unlock()
+			}))
+			defer server.Close()
+
+			s := NewService("s1", server.URL+"/try", server.URL+"/confirm", server.URL+"/cancel",
+				WithClient(server.Client()), WithServiceOptions(tt.opts...))
+			if err := tcc.NewTransaction([]*tcc.Service{s}).Wait(); err != nil {
+				t.Fatalf("Transaction.Wait() error = %v", err)
+			}
+			if want := []string{tt.want, tt.want}; !reflect.DeepEqual(got, want) {
+				t.Errorf("%s of try and confirm = %q, want %q", IsolationHeader, got, want)
+			}
+		})
+	}
+}
+
+func TestNewService_largeResponse(t *testing.T) {
+	var mu sync.Mutex
+	tries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/try" {
+			mu.Lock()
+			tries++
+			mu.Unlock()
+			_, _ = w.Write(make([]byte, maxResponseSize+1))
+		}
+	}))
+	defer server.Close()
+
+	s := NewService("s1", server.URL+"/try", server.URL+"/confirm", server.URL+"/cancel")
+	tx := tcc.NewTransaction([]*tcc.Service{s}, tcc.WithTryRetries(1), tcc.WithMaxRetries(0))
+	if err := tx.Wait(); err == nil || tx.Status() != tcc.StatusCanceled {
+		t.Errorf("Transaction.Wait() error = %v, status = %v, want canceled", err, tx.Status())
+	}
+	if tries != 1 {
+		t.Errorf("try called %d times, want 1 because the error is permanent", tries)
+	}
+}
+
+func TestWithDTM(t *testing.T) {
+	tests := []struct {
+		name        string
+		tryStatus   int
+		tryBody     string
+		wantStatus  tcc.Status
+		wantQueries []string
+	}{
+		{
+			name:       "confirmed",
+			tryStatus:  http.StatusOK,
+			tryBody:    `{"dtm_result":"SUCCESS"}`,
+			wantStatus: tcc.StatusConfirmed,
+			wantQueries: []string{
+				"branch_id=01&gid=tx1&op=try&trans_type=tcc",
+				"branch_id=01&gid=tx1&op=confirm&trans_type=tcc",
+			},
+		},
+		{
+			name:       "failure status",
+			tryStatus:  http.StatusConflict,
+			wantStatus: tcc.StatusCanceled,
+			wantQueries: []string{
+				"branch_id=01&gid=tx1&op=try&trans_type=tcc",
+			},
+		},
+		{
+			name:       "failure body",
+			tryStatus:  http.StatusOK,
+			tryBody:    `{"dtm_result":"FAILURE"}`,
+			wantStatus: tcc.StatusCanceled,
+			wantQueries: []string{
+				"branch_id=01&gid=tx1&op=try&trans_type=tcc",
+			},
+		},
+		{
+			name:       "failure in other field",
+			tryStatus:  http.StatusOK,
+			tryBody:    `{"dtm_result":"SUCCESS","message":"FAILURE of the last attempt is retried"}`,
+			wantStatus: tcc.StatusConfirmed,
+			wantQueries: []string{
+				"branch_id=01&gid=tx1&op=try&trans_type=tcc",
+				"branch_id=01&gid=tx1&op=confirm&trans_type=tcc",
+			},
+		},
+		{
+			name:       "ongoing",
+			tryStatus:  http.StatusTooEarly,
+			wantStatus: tcc.StatusCanceled,
+			wantQueries: []string{
+				"branch_id=01&gid=tx1&op=try&trans_type=tcc",
+				"branch_id=01&gid=tx1&op=try&trans_type=tcc",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var queries, bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				queries = append(queries, r.URL.RawQuery)
+				bodies = append(bodies, string(body))
+				mu.Unlock()
+				if r.URL.Query().Get("op") == "try" {
+					w.WriteHeader(tt.tryStatus)
+					_, _ = w.Write([]byte(tt.tryBody))
+				}
+			}))
+			defer server.Close()
+
+			s := NewService("s1", server.URL+"/try", server.URL+"/confirm", server.URL+"/cancel", WithDTM("01"),
+				WithBody(func(phase tcc.Phase, tryResult []byte) ([]byte, error) {
+					if tryResult != nil {
+						t.Errorf("%s got try result %q", phase, tryResult)
+					}
+					return []byte(`{"amount":30}`), nil
+				}))
+			tx := tcc.NewTransaction([]*tcc.Service{s}, tcc.WithTxId("tx1"), tcc.WithTryRetries(1), tcc.WithMaxRetries(0),
+				tcc.WithDecorrelatedJitter(time.Millisecond, time.Millisecond))
+			_ = tx.Wait()
+			if got := tx.Status(); got != tt.wantStatus {
+				t.Errorf("Transaction.Status() = %v, want %v", got, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(queries, tt.wantQueries) {
+				t.Errorf("queries = %v, want %v", queries, tt.wantQueries)
+			}
+			for _, body := range bodies {
+				if body != `{"amount":30}` {
+					t.Errorf("body = %q, want the same payload in every phase", body)
+				}
+			}
+		})
+	}
+}
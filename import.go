@@ -0,0 +1,224 @@
+package tcc
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ImportRecord is a transaction in flight in another TCC implementation, imported by ImportJSON.
+type ImportRecord struct {
+	TxId string `json:"tx_id"`
+
+	// Status is "trying", "confirming" or "canceling".
+	Status string `json:"status"`
+
+	// StartedAt is when the transaction started, in RFC 3339. It is the time of the import if it is empty.
+	StartedAt string `json:"started_at,omitempty"`
+
+	Services []ImportService `json:"services"`
+}
+
+// ImportService is a reservation of a service in ImportRecord.
+type ImportService struct {
+	Name             string `json:"name"`
+	TrySucceeded     bool   `json:"try_succeeded"`
+	ConfirmSucceeded bool   `json:"confirm_succeeded"`
+	CancelSucceeded  bool   `json:"cancel_succeeded"`
+
+	// TryResult is the result of try passed to confirm and cancel.
+	TryResult string `json:"try_result,omitempty"`
+}
+
+// ImportOption can set option to ImportJSON and ImportCSV.
+type ImportOption func(i *importer)
+
+// WithImportOverwrite makes the import replace transactions already saved with the same ID,
+// instead of skipping them. Recovery may have finished them since they were exported,
+// so overwriting can move them back to the imported status.
+func WithImportOverwrite() ImportOption {
+	return func(i *importer) {
+		i.overwrite = true
+	}
+}
+
+type importer struct {
+	store     Store
+	overwrite bool
+	now       time.Time
+}
+
+func newImporter(store Store, opts []ImportOption) *importer {
+	i := &importer{store: store, now: time.Now()}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// ImportJSON saves transactions of ImportRecord objects read from r, e.g. one per line, to store,
+// so that Recover finishes them like transactions left by a crash. It returns how many transactions are saved.
+// Transactions already saved with the same ID are skipped, so running the same import again is safe;
+// checking them requires store to implement Getter unless WithImportOverwrite is set.
+// It stops at the first invalid record.
+func ImportJSON(ctx context.Context, store Store, r io.Reader, opts ...ImportOption) (int, error) {
+	i := newImporter(store, opts)
+	dec := json.NewDecoder(r)
+	n := 0
+	for {
+		var record ImportRecord
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("import record %d: %w", n+1, err)
+		}
+		saved, err := i.importRecord(ctx, &record)
+		if err != nil {
+			return n, err
+		}
+		if saved {
+			n++
+		}
+	}
+}
+
+// ImportCSV is ImportJSON of CSV with a header row and a row for every service of a transaction:
+//
+//	tx_id,status,started_at,service,try_succeeded,confirm_succeeded,cancel_succeeded,try_result
+//
+// Rows of a transaction have to be adjacent, and the status and started_at of its first row are used.
+// A transaction whose rows are not adjacent is an error, instead of replacing the one saved from its first rows.
+func ImportCSV(ctx context.Context, store Store, r io.Reader, opts ...ImportOption) (int, error) {
+	i := newImporter(store, opts)
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 8
+	if _, err := cr.Read(); err != nil {
+		return 0, fmt.Errorf("import header: %w", err)
+	}
+	n := 0
+	var record *ImportRecord
+	imported := map[string]bool{}
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return n, fmt.Errorf("import line %d: %w", line, err)
+		}
+		if record != nil && record.TxId != row[0] {
+			saved, err := i.importRecord(ctx, record)
+			if err != nil {
+				return n, err
+			}
+			if saved {
+				n++
+			}
+			imported[record.TxId] = true
+			record = nil
+		}
+		if record == nil {
+			if imported[row[0]] {
+				return n, fmt.Errorf("import line %d: rows of transaction %s are not adjacent", line, row[0])
+			}
+			record = &ImportRecord{TxId: row[0], Status: row[1], StartedAt: row[2]}
+		}
+		s := ImportService{Name: row[3], TryResult: row[7]}
+		for j, b := range []*bool{&s.TrySucceeded, &s.ConfirmSucceeded, &s.CancelSucceeded} {
+			if row[4+j] == "" {
+				continue
+			}
+			if *b, err = strconv.ParseBool(row[4+j]); err != nil {
+				return n, fmt.Errorf("import line %d: %w", line, err)
+			}
+		}
+		record.Services = append(record.Services, s)
+	}
+	if record == nil {
+		return n, nil
+	}
+	saved, err := i.importRecord(ctx, record)
+	if err != nil {
+		return n, err
+	}
+	if saved {
+		n++
+	}
+	return n, nil
+}
+
+// importRecord saves the transaction of record, and reports false if it is skipped because it is already saved.
+func (i *importer) importRecord(ctx context.Context, record *ImportRecord) (bool, error) {
+	state, err := record.txState(i.now)
+	if err != nil {
+		return false, fmt.Errorf("import transaction %s: %w", record.TxId, err)
+	}
+	if !i.overwrite {
+		getter, ok := i.store.(Getter)
+		if !ok {
+			return false, fmt.Errorf("import transaction %s: %T does not implement Getter to skip saved transactions", record.TxId, i.store)
+		}
+		_, err := getter.GetTx(ctx, record.TxId)
+		if err == nil {
+			return false, nil
+		}
+		if !errors.Is(err, ErrTxNotFound) {
+			return false, &CoordinatorError{op: "look up transaction " + record.TxId, err: err}
+		}
+	}
+	if err := i.store.SaveTxState(ctx, state); err != nil {
+		return false, &CoordinatorError{op: "import transaction " + record.TxId, err: err}
+	}
+	return true, nil
+}
+
+// txState returns the state of the record, which started at now unless StartedAt is set.
+func (record *ImportRecord) txState(now time.Time) (*TxState, error) {
+	if record.TxId == "" {
+		return nil, errors.New("tx_id is required")
+	}
+	state := &TxState{TxId: record.TxId, StartedAt: now}
+	switch record.Status {
+	case StatusTrying.String():
+		state.Status = StatusTrying
+	case StatusConfirming.String():
+		state.Status = StatusConfirming
+	case StatusCanceling.String():
+		state.Status = StatusCanceling
+	default:
+		return nil, fmt.Errorf("status must be trying, confirming or canceling: %q", record.Status)
+	}
+	if record.StartedAt != "" {
+		startedAt, err := time.Parse(time.RFC3339, record.StartedAt)
+		if err != nil {
+			return nil, err
+		}
+		state.StartedAt = startedAt
+	}
+	if len(record.Services) == 0 {
+		return nil, errors.New("services are required")
+	}
+	for _, s := range record.Services {
+		if s.Name == "" {
+			return nil, errors.New("name of service is required")
+		}
+		ss := ServiceState{
+			Name:             s.Name,
+			TrySucceeded:     s.TrySucceeded,
+			ConfirmSucceeded: s.ConfirmSucceeded,
+			CancelSucceeded:  s.CancelSucceeded,
+		}
+		if s.TryResult != "" {
+			ss.TryResult = []byte(s.TryResult)
+		}
+		state.Services = append(state.Services, ss)
+	}
+	return state, nil
+}
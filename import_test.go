@@ -0,0 +1,140 @@
+package tcc
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportJSON(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	input := `{"tx_id":"tx1","status":"confirming","started_at":"2026-01-02T03:04:05Z","services":[
+	{"name":"s1","try_succeeded":true,"confirm_succeeded":true},{"name":"s2","try_succeeded":true,"try_result":"r2"}]}
+{"tx_id":"tx2","status":"canceling","services":[{"name":"s1","try_succeeded":true}]}
+`
+	before := time.Now()
+	n, err := ImportJSON(ctx, store, strings.NewReader(input))
+	if err != nil || n != 2 {
+		t.Fatalf("ImportJSON() = %d, %v, want 2", n, err)
+	}
+	states, _ := store.LoadPendingTx(ctx)
+	sort.Slice(states, func(i, j int) bool { return states[i].TxId < states[j].TxId })
+	// a transaction without started_at starts at the import
+	if startedAt := states[1].StartedAt; startedAt.Before(before) || startedAt.After(time.Now()) {
+		t.Errorf("StartedAt of tx2 = %v, want the time of the import", startedAt)
+	}
+	states[1].StartedAt = time.Time{}
+	want := []*TxState{
+		{TxId: "tx1", Status: StatusConfirming, StartedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Services: []ServiceState{
+			{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true},
+			{Name: "s2", TrySucceeded: true, TryResult: []byte("r2")},
+		}},
+		{TxId: "tx2", Status: StatusCanceling, Services: []ServiceState{{Name: "s1", TrySucceeded: true}}},
+	}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("imported %+v, want %+v", states, want)
+	}
+
+	var confirmed []string
+	services := []*Service{
+		NewService("s1", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+		NewServiceWithResult("s2",
+			func(context.Context) ([]byte, error) { return nil, nil },
+			func(ctx context.Context, tryResult []byte) error {
+				confirmed = append(confirmed, string(tryResult))
+				return nil
+			},
+			func(ctx context.Context, tryResult []byte) error { return nil }),
+	}
+	if err := Recover(ctx, store, services); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if !reflect.DeepEqual(confirmed, []string{"r2"}) {
+		t.Errorf("confirmed %v, want [r2]", confirmed)
+	}
+}
+
+func TestImportJSON_again(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	input := `{"tx_id":"tx1","status":"confirming","services":[{"name":"s1","try_succeeded":true}]}`
+	if n, err := ImportJSON(ctx, store, strings.NewReader(input)); err != nil || n != 1 {
+		t.Fatalf("ImportJSON() = %d, %v, want 1", n, err)
+	}
+	// recovery confirms the transaction before the import is run again
+	_ = store.SaveTxState(ctx, &TxState{TxId: "tx1", Status: StatusConfirmed, Services: []ServiceState{{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true}}})
+	if n, err := ImportJSON(ctx, store, strings.NewReader(input)); err != nil || n != 0 {
+		t.Errorf("ImportJSON() again = %d, %v, want 0", n, err)
+	}
+	if state, _ := store.(Getter).GetTx(ctx, "tx1"); state.Status != StatusConfirmed {
+		t.Errorf("status after importing again = %v, want %v", state.Status, StatusConfirmed)
+	}
+	if n, err := ImportJSON(ctx, store, strings.NewReader(input), WithImportOverwrite()); err != nil || n != 1 {
+		t.Errorf("ImportJSON() with WithImportOverwrite = %d, %v, want 1", n, err)
+	}
+	if state, _ := store.(Getter).GetTx(ctx, "tx1"); state.Status != StatusConfirming {
+		t.Errorf("status after overwriting = %v, want %v", state.Status, StatusConfirming)
+	}
+}
+
+func TestImportJSON_invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "finished", input: `{"tx_id":"tx1","status":"confirmed","services":[{"name":"s1"}]}`},
+		{name: "no services", input: `{"tx_id":"tx1","status":"trying"}`},
+		{name: "no id", input: `{"status":"trying","services":[{"name":"s1"}]}`},
+		{name: "started_at", input: `{"tx_id":"tx1","status":"trying","started_at":"yesterday","services":[{"name":"s1"}]}`},
+		{name: "syntax", input: `{"tx_id":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := ImportJSON(context.Background(), NewMemoryStore(), strings.NewReader(tt.input))
+			if err == nil || n != 0 {
+				t.Errorf("ImportJSON() = %d, %v, want error", n, err)
+			}
+		})
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	input := `tx_id,status,started_at,service,try_succeeded,confirm_succeeded,cancel_succeeded,try_result
+tx1,trying,,s1,true,,,
+tx1,trying,,s2,false,,,
+tx2,confirming,,s1,true,false,false,r1
+`
+	n, err := ImportCSV(ctx, store, strings.NewReader(input))
+	if err != nil || n != 2 {
+		t.Fatalf("ImportCSV() = %d, %v, want 2", n, err)
+	}
+	states, _ := store.LoadPendingTx(ctx)
+	sort.Slice(states, func(i, j int) bool { return states[i].TxId < states[j].TxId })
+	for _, state := range states {
+		state.StartedAt = time.Time{}
+	}
+	want := []*TxState{
+		{TxId: "tx1", Status: StatusTrying, Services: []ServiceState{{Name: "s1", TrySucceeded: true}, {Name: "s2"}}},
+		{TxId: "tx2", Status: StatusConfirming, Services: []ServiceState{{Name: "s1", TrySucceeded: true, TryResult: []byte("r1")}}},
+	}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("imported %+v, want %+v", states, want)
+	}
+
+	if _, err := ImportCSV(ctx, store, strings.NewReader("header\n")); err == nil {
+		t.Error("ImportCSV() of header with wrong fields succeeded")
+	}
+	if _, err := ImportCSV(ctx, store, strings.NewReader(strings.Replace(input, "true,false", "yes,false", 1))); err == nil {
+		t.Error("ImportCSV() of invalid bool succeeded")
+	}
+	split := input + "tx1,trying,,s3,true,,,\n"
+	if n, err := ImportCSV(ctx, NewMemoryStore(), strings.NewReader(split)); err == nil || n != 2 {
+		t.Errorf("ImportCSV() of transaction with rows not adjacent = %d, %v, want error after 2", n, err)
+	}
+}
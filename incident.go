@@ -0,0 +1,208 @@
+package tcc
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxIncidentTxIds is the number of IDs of affected transactions kept in Incident.
+const maxIncidentTxIds = 100
+
+// Incident is a participant-level failure, opened when many transactions failed on the same service within a window.
+type Incident struct {
+	// Service is the name of the service.
+	Service string
+
+	// OpenedAt is when the incident is opened.
+	OpenedAt time.Time
+
+	// Failures is how many transactions failed on the service since the window before the incident opened.
+	Failures int
+
+	// TxIds are IDs of the first affected transactions.
+	TxIds []string
+
+	// ResolvedAt is when the incident is resolved, zero while it is open.
+	ResolvedAt time.Time
+}
+
+// IncidentTracker collapses failures of many transactions on the same service into an Incident,
+// so that operators get a single alert for the participant instead of an alert for every transaction.
+// It can be shared by multiple directors like HealthRegistry.
+type IncidentTracker struct {
+	window    time.Duration
+	threshold int
+	onOpen    func(Incident)
+	onResolve func(Incident)
+	now       func() time.Time
+
+	mu        sync.Mutex
+	failures  map[string][]failure
+	incidents map[string]*Incident
+}
+
+type failure struct {
+	at   time.Time
+	txId string
+}
+
+// IncidentOption can set option to IncidentTracker.
+type IncidentOption func(t *IncidentTracker)
+
+// WithOnResolve sets function called with the incident when it is resolved,
+// e.g. to close the alert and re-drive transactions stuck on the service.
+func WithOnResolve(f func(Incident)) IncidentOption {
+	return func(t *IncidentTracker) {
+		t.onResolve = f
+	}
+}
+
+// NewIncidentTracker returns IncidentTracker which opens an incident of a service
+// when threshold transactions failed on it within window, calling onOpen once for the incident.
+// Failures of an open incident are added to it without calling onOpen again.
+// The incident is resolved by Resolve, or when a transaction including the service succeeds,
+// which shows the service is healthy again.
+func NewIncidentTracker(window time.Duration, threshold int, onOpen func(Incident), opts ...IncidentOption) *IncidentTracker {
+	t := &IncidentTracker{
+		window:    window,
+		threshold: threshold,
+		onOpen:    onOpen,
+		now:       time.Now,
+		failures:  map[string][]failure{},
+		incidents: map[string]*Incident{},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// WithIncidentTracker sets IncidentTracker which is told every service that failed a transaction
+// with a retryable error, e.g. a timeout or an outage, in try as well as in confirm or cancel,
+// and every service of a transaction which succeeded.
+// Non-retryable rejections like insufficient balance, and transactions refused before calling the service, are not told.
+func WithIncidentTracker(t *IncidentTracker) Option {
+	return func(d *director) {
+		d.incidents = t
+	}
+}
+
+// Incident returns the open incident of the service.
+func (t *IncidentTracker) Incident(service string) (Incident, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	incident, ok := t.incidents[service]
+	if !ok {
+		return Incident{}, false
+	}
+	return copyIncident(incident), true
+}
+
+// Incidents returns every open incident, ordered by service.
+func (t *IncidentTracker) Incidents() []Incident {
+	t.mu.Lock()
+	incidents := make([]Incident, 0, len(t.incidents))
+	for _, incident := range t.incidents {
+		incidents = append(incidents, copyIncident(incident))
+	}
+	t.mu.Unlock()
+	sort.Slice(incidents, func(i, j int) bool { return incidents[i].Service < incidents[j].Service })
+	return incidents
+}
+
+// Resolve resolves the open incident of the service, and reports if there was one.
+func (t *IncidentTracker) Resolve(service string) bool {
+	t.mu.Lock()
+	incident, ok := t.incidents[service]
+	if !ok {
+		t.mu.Unlock()
+		return false
+	}
+	delete(t.incidents, service)
+	incident.ResolvedAt = t.now()
+	resolved := copyIncident(incident)
+	t.mu.Unlock()
+	if t.onResolve != nil {
+		t.onResolve(resolved)
+	}
+	return true
+}
+
+// record records the transaction failed on the service.
+func (t *IncidentTracker) record(service, txId string) {
+	t.mu.Lock()
+	now := t.now()
+	if incident, ok := t.incidents[service]; ok {
+		incident.Failures++
+		if len(incident.TxIds) < maxIncidentTxIds {
+			incident.TxIds = append(incident.TxIds, txId)
+		}
+		t.mu.Unlock()
+		return
+	}
+	recent := t.failures[service][:0]
+	for _, f := range t.failures[service] {
+		if now.Sub(f.at) < t.window {
+			recent = append(recent, f)
+		}
+	}
+	recent = append(recent, failure{at: now, txId: txId})
+	if len(recent) < t.threshold {
+		t.failures[service] = recent
+		t.mu.Unlock()
+		return
+	}
+	incident := &Incident{Service: service, OpenedAt: now, Failures: len(recent)}
+	for _, f := range recent {
+		if len(incident.TxIds) < maxIncidentTxIds {
+			incident.TxIds = append(incident.TxIds, f.txId)
+		}
+	}
+	t.incidents[service] = incident
+	delete(t.failures, service)
+	opened := copyIncident(incident)
+	t.mu.Unlock()
+	if t.onOpen != nil {
+		t.onOpen(opened)
+	}
+}
+
+func copyIncident(incident *Incident) Incident {
+	c := *incident
+	c.TxIds = append([]string(nil), incident.TxIds...)
+	return c
+}
+
+// trackIncidents tells IncidentTracker the services which failed the transaction with err,
+// or resolves incidents of the services if it succeeded.
+func (d *director) trackIncidents(err error) {
+	if d.incidents == nil {
+		return
+	}
+	if err == nil {
+		for _, s := range d.services {
+			d.incidents.Resolve(s.name)
+		}
+		return
+	}
+	for _, e := range serviceErrors(err) {
+		if d.participantFailure(e.err) {
+			d.incidents.record(e.serviceName, d.txId)
+		}
+	}
+}
+
+// participantFailure reports if err tells the participant is failing, so that it counts toward an incident.
+// Business rejections which are not retryable, refusals by HealthRegistry or ResourceLocker,
+// aborts and errors of the director itself tell nothing about the participant.
+func (d *director) participantFailure(err error) bool {
+	var ce *CoordinatorError
+	switch {
+	case errors.Is(err, ErrDependencyDown), errors.Is(err, ErrInMaintenance), errors.Is(err, ErrDraining),
+		errors.Is(err, ErrResourceBusy), errors.Is(err, ErrAborted), errors.As(err, &ce):
+		return false
+	}
+	return d.retryable(err)
+}
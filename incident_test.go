@@ -0,0 +1,127 @@
+package tcc
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestIncidentTracker(t *testing.T) {
+	var opened []Incident
+	tracker := NewIncidentTracker(time.Minute, 3, func(i Incident) { opened = append(opened, i) })
+	now := time.Unix(0, 0)
+	tracker.now = func() time.Time { return now }
+
+	tracker.record("s1", "tx1")
+	now = now.Add(2 * time.Minute)
+	tracker.record("s1", "tx2")
+	tracker.record("s2", "tx3")
+	tracker.record("s1", "tx4")
+	if _, ok := tracker.Incident("s1"); ok || len(opened) != 0 {
+		t.Fatalf("incident opened by failures out of window: %+v", opened)
+	}
+	tracker.record("s1", "tx5")
+	tracker.record("s1", "tx6")
+	tracker.record("s1", "tx7")
+	if len(opened) != 1 {
+		t.Fatalf("opened %d incidents, want 1", len(opened))
+	}
+	if want := []string{"tx2", "tx4", "tx5"}; opened[0].Service != "s1" || opened[0].Failures != 3 ||
+		fmt.Sprint(opened[0].TxIds) != fmt.Sprint(want) {
+		t.Errorf("opened %+v, want failures of %v", opened[0], want)
+	}
+	incident, ok := tracker.Incident("s1")
+	if !ok || incident.Failures != 5 || len(incident.TxIds) != 5 {
+		t.Errorf("Incident(s1) = %+v, %v, want 5 failures", incident, ok)
+	}
+	if _, ok := tracker.Incident("s2"); ok {
+		t.Error("Incident(s2) is open")
+	}
+}
+
+func TestWithIncidentTracker(t *testing.T) {
+	var opened []Incident
+	tracker := NewIncidentTracker(time.Minute, 2, func(i Incident) { opened = append(opened, i) })
+	for i := 0; i < 3; i++ {
+		d := NewDirector([]*Service{
+			NewService("s1", func() error { return errors.New("try") }, func() error { return nil }, func() error { return nil }),
+			NewService("s2", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+		}, WithIncidentTracker(tracker), WithMaxRetries(1), WithDecorrelatedJitter(time.Millisecond, time.Millisecond))
+		if err := d.Direct(); err == nil {
+			t.Fatal("Direct() succeeded")
+		}
+	}
+	if len(opened) != 1 || opened[0].Service != "s1" {
+		t.Fatalf("opened %+v, want an incident of s1", opened)
+	}
+	if incident, _ := tracker.Incident("s1"); incident.Failures != 3 {
+		t.Errorf("Incident(s1).Failures = %d, want 3", incident.Failures)
+	}
+	if _, ok := tracker.Incident("s2"); ok {
+		t.Error("Incident(s2) is open")
+	}
+}
+
+func TestWithIncidentTracker_notParticipantFailures(t *testing.T) {
+	health := NewHealthRegistry()
+	health.MarkDown("down")
+	tests := []struct {
+		name string
+		s    *Service
+	}{
+		{
+			name: "business rejection",
+			s:    NewService("s1", func() error { return WithCode(errors.New("insufficient balance"), CodeConflict) }, func() error { return nil }, func() error { return nil }),
+		},
+		{
+			name: "permanent error",
+			s:    NewService("s1", func() error { return WithCode(errors.New("bad request"), CodePermanent) }, func() error { return nil }, func() error { return nil }),
+		},
+		{
+			name: "refused by health registry",
+			s:    NewService("down", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewIncidentTracker(time.Minute, 1, nil)
+			d := NewDirector([]*Service{tt.s}, WithIncidentTracker(tracker), WithHealthRegistry(health), WithMaxRetries(0))
+			if err := d.Direct(); err == nil {
+				t.Fatal("Direct() succeeded")
+			}
+			if incidents := tracker.Incidents(); len(incidents) != 0 {
+				t.Errorf("Incidents() = %+v, want none", incidents)
+			}
+		})
+	}
+}
+
+func TestIncidentTracker_Resolve(t *testing.T) {
+	var resolved []Incident
+	tracker := NewIncidentTracker(time.Minute, 1, nil, WithOnResolve(func(i Incident) { resolved = append(resolved, i) }))
+	tracker.record("s2", "tx1")
+	tracker.record("s1", "tx2")
+	if incidents := tracker.Incidents(); len(incidents) != 2 || incidents[0].Service != "s1" {
+		t.Fatalf("Incidents() = %+v, want s1 and s2", incidents)
+	}
+	if !tracker.Resolve("s2") || tracker.Resolve("s2") {
+		t.Error("Resolve(s2) does not resolve the incident once")
+	}
+	if len(resolved) != 1 || resolved[0].Service != "s2" || resolved[0].ResolvedAt.IsZero() {
+		t.Errorf("resolved %+v, want s2", resolved)
+	}
+
+	d := NewDirector([]*Service{
+		NewService("s1", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+	}, WithIncidentTracker(tracker))
+	if err := d.Direct(); err != nil {
+		t.Fatal(err)
+	}
+	if incidents := tracker.Incidents(); len(incidents) != 0 {
+		t.Errorf("Incidents() after a transaction succeeded = %+v", incidents)
+	}
+	if len(resolved) != 2 || resolved[1].Service != "s1" {
+		t.Errorf("resolved %+v, want s2 and s1", resolved)
+	}
+}
@@ -0,0 +1,64 @@
+package tcc
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+// jitterSeeds makes seeds of jitters created at the same time differ.
+var jitterSeeds int64
+
+// WithDecorrelatedJitter makes confirm and cancel retries wait by decorrelated jitter between base and max,
+// instead of exponential backoff. The number of retries is still limited by WithMaxRetries.
+// Each retried call draws its waits from its own random source, so transactions failed against a participant
+// at the same time, e.g. during its outage, spread their retries out instead of hitting it together when it recovers.
+func WithDecorrelatedJitter(base, max time.Duration) Option {
+	return func(d *director) {
+		d.delays = func() backoff.BackOff { return NewDecorrelatedJitter(base, max) }
+	}
+}
+
+// NewDecorrelatedJitter returns backoff.BackOff which waits a random duration
+// between base and 3 times the previous wait, capped by max.
+// It is safe for concurrent use, and can be returned by the function passed to WithResourceLockWait too.
+func NewDecorrelatedJitter(base, max time.Duration) backoff.BackOff {
+	seed := time.Now().UnixNano() + atomic.AddInt64(&jitterSeeds, 1)
+	return &decorrelatedJitter{
+		base:  base,
+		max:   max,
+		sleep: base,
+		rand:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+type decorrelatedJitter struct {
+	base, max time.Duration
+
+	sleep time.Duration
+	rand  *rand.Rand
+	mu    sync.Mutex
+}
+
+func (j *decorrelatedJitter) NextBackOff() time.Duration {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	sleep := j.base
+	if spread := 3*j.sleep - j.base; spread > 0 {
+		sleep += time.Duration(j.rand.Int63n(int64(spread)))
+	}
+	if sleep > j.max {
+		sleep = j.max
+	}
+	j.sleep = sleep
+	return sleep
+}
+
+func (j *decorrelatedJitter) Reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.sleep = j.base
+}
@@ -0,0 +1,50 @@
+package tcc
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_decorrelatedJitter(t *testing.T) {
+	base, max := 10*time.Millisecond, time.Second
+	j := NewDecorrelatedJitter(base, max)
+	prev := base
+	for i := 0; i < 100; i++ {
+		got := j.NextBackOff()
+		if got < base || got > max || got > 3*prev {
+			t.Fatalf("NextBackOff() = %v, want between %v and min(%v, %v)", got, base, max, 3*prev)
+		}
+		prev = got
+	}
+	j.Reset()
+	if got := j.NextBackOff(); got > 3*base {
+		t.Errorf("NextBackOff() after Reset() = %v, want at most %v", got, 3*base)
+	}
+}
+
+func Test_decorrelatedJitter_Decorrelated(t *testing.T) {
+	base, max := time.Millisecond, time.Hour
+	j1, j2 := NewDecorrelatedJitter(base, max), NewDecorrelatedJitter(base, max)
+	same := 0
+	for i := 0; i < 10; i++ {
+		if j1.NextBackOff() == j2.NextBackOff() {
+			same++
+		}
+	}
+	if same == 10 {
+		t.Errorf("jitters created at the same time wait the same durations")
+	}
+}
+
+func TestWithDecorrelatedJitter(t *testing.T) {
+	d := newDirector(nil, WithDecorrelatedJitter(time.Millisecond, time.Second), WithMaxRetries(2))
+	b := d.backoff()
+	for i := 0; i < 2; i++ {
+		if got := b.NextBackOff(); got < time.Millisecond || got > time.Second {
+			t.Errorf("NextBackOff() = %v, want jitter", got)
+		}
+	}
+	if got := b.NextBackOff(); got != -1 {
+		t.Errorf("NextBackOff() after max retries = %v, want stop", got)
+	}
+}
@@ -0,0 +1,46 @@
+package tcc
+
+import (
+	"errors"
+	"time"
+)
+
+// Logger logs events of transactions as a message with alternating keys and values,
+// like "service", "s1", "phase", PhaseTry. Every event has "txId" as the first key.
+// It is small enough to be adapted to most structured loggers.
+type Logger interface {
+	// Info logs a phase started, finished or retried, and a transaction finished.
+	Info(msg string, keyvals ...interface{})
+
+	// Error logs a phase failed, and a transaction failed.
+	Error(msg string, keyvals ...interface{})
+}
+
+// WithLogger sets Logger which logs phases and failures of the transaction.
+func WithLogger(l Logger) Option {
+	return func(d *director) {
+		d.logger = l
+	}
+}
+
+func (d *director) logInfo(msg string, keyvals ...interface{}) {
+	if d.logger != nil {
+		d.logger.Info(msg, append([]interface{}{"txId", d.txId}, keyvals...)...)
+	}
+}
+
+func (d *director) logError(msg string, keyvals ...interface{}) {
+	if d.logger != nil {
+		d.logger.Error(msg, append([]interface{}{"txId", d.txId}, keyvals...)...)
+	}
+}
+
+// logFailure logs err the transaction finished with, unpacking *Error.
+func (d *director) logFailure(status Status, elapsed time.Duration, err error) {
+	keyvals := []interface{}{"status", status, "elapsed", elapsed}
+	var e *Error
+	if errors.As(err, &e) {
+		keyvals = append(keyvals, "failedPhase", e.FailedPhase(), "service", e.ServiceName())
+	}
+	d.logError("transaction failed", append(keyvals, "error", err)...)
+}
@@ -0,0 +1,55 @@
+package tcc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type recordingLogger struct {
+	lines []string
+	sync.Mutex
+}
+
+func (l *recordingLogger) log(level, msg string, keyvals []interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	line := level + " " + msg
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if keyvals[i] == "elapsed" {
+			continue
+		}
+		line += fmt.Sprintf(" %v=%v", keyvals[i], keyvals[i+1])
+	}
+	l.lines = append(l.lines, line)
+}
+
+func (l *recordingLogger) Info(msg string, keyvals ...interface{}) { l.log("info", msg, keyvals) }
+
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.log("error", msg, keyvals) }
+
+func TestWithLogger(t *testing.T) {
+	l := &recordingLogger{}
+	d := newDirector([]*Service{
+		NewService(
+			"s1",
+			func() error { return errors.New("out of stock") },
+			func() error { return nil },
+			func() error { return nil },
+		),
+	}, WithLogger(l))
+	d.txId = "tx1"
+	if err := d.Direct(); err == nil {
+		t.Fatal("director.Direct() error = nil, wantErr")
+	}
+	want := []string{
+		"info phase started txId=tx1 service=s1 phase=try",
+		"error phase failed txId=tx1 service=s1 phase=try error=out of stock",
+		"error transaction failed txId=tx1 status=canceled failedPhase=0 service=s1 error=out of stock",
+	}
+	if !reflect.DeepEqual(l.lines, want) {
+		t.Errorf("logs = %q, want %q", l.lines, want)
+	}
+}
@@ -0,0 +1,49 @@
+package tcc
+
+import "time"
+
+// Metrics records how transactions go, e.g. to export them to Prometheus.
+// Methods are called concurrently by every service of every transaction sharing it,
+// so they need to be safe for concurrent use and fast.
+type Metrics interface {
+	// TxStarted is called when a transaction is started by Direct or DirectContext, or resumed by Resume or Recover.
+	// Transactions started minus finished are the ones in flight.
+	TxStarted()
+
+	// TxFinished is called with the final status and the duration when the transaction started by TxStarted finishes.
+	TxFinished(status Status, elapsed time.Duration)
+
+	// PhaseDone is called after every call to a phase function of a service, including retries,
+	// with the error it returned and the duration.
+	PhaseDone(serviceName string, phase Phase, err error, elapsed time.Duration)
+
+	// Retried is called before a try, confirm or cancel is called again after a failure.
+	Retried(serviceName string, phase Phase)
+}
+
+// WithMetrics sets Metrics which records transactions of the director.
+func WithMetrics(m Metrics) Option {
+	return func(d *director) {
+		d.metrics = m
+	}
+}
+
+// measure runs f, the whole run of the transaction, reporting it to Metrics and Logger.
+func (d *director) measure(f func() error) error {
+	if d.metrics != nil {
+		d.metrics.TxStarted()
+	}
+	start := time.Now()
+	err := f()
+	status, elapsed := d.Status(), time.Since(start)
+	if d.metrics != nil {
+		d.metrics.TxFinished(status, elapsed)
+	}
+	d.trackIncidents(err)
+	if err != nil {
+		d.logFailure(status, elapsed, err)
+	} else {
+		d.logInfo("transaction finished", "status", status, "elapsed", elapsed)
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package tcc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+type recordingMetrics struct {
+	events []string
+	sync.Mutex
+}
+
+func (m *recordingMetrics) record(format string, args ...interface{}) {
+	m.Lock()
+	defer m.Unlock()
+	m.events = append(m.events, fmt.Sprintf(format, args...))
+}
+
+func (m *recordingMetrics) TxStarted() { m.record("started") }
+
+func (m *recordingMetrics) TxFinished(status Status, elapsed time.Duration) {
+	m.record("finished %s", status)
+}
+
+func (m *recordingMetrics) PhaseDone(serviceName string, phase Phase, err error, elapsed time.Duration) {
+	m.record("%s %s %v", phase, serviceName, err)
+}
+
+func (m *recordingMetrics) Retried(serviceName string, phase Phase) {
+	m.record("retried %s %s", phase, serviceName)
+}
+
+func TestWithMetrics(t *testing.T) {
+	m := &recordingMetrics{}
+	confirmErrs := []error{errors.New("test"), nil}
+	d := newDirector([]*Service{
+		NewService(
+			"s1",
+			func() error { return nil },
+			func() error { err := confirmErrs[0]; confirmErrs = confirmErrs[1:]; return err },
+			func() error { return nil },
+		),
+	}, WithMetrics(m))
+	d.backoff = func() backoff.BackOff { return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 3) }
+	if err := d.Direct(); err != nil {
+		t.Fatalf("director.Direct() error = %v", err)
+	}
+	want := []string{
+		"started",
+		"try s1 <nil>",
+		"confirm s1 test",
+		"retried confirm s1",
+		"confirm s1 <nil>",
+		"finished confirmed",
+	}
+	if !reflect.DeepEqual(m.events, want) {
+		t.Errorf("metrics = %q, want %q", m.events, want)
+	}
+}
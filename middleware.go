@@ -0,0 +1,41 @@
+package tcc
+
+import "context"
+
+// Middleware wraps every phase call of services, e.g. with logging, metrics, auth, or panic recovery.
+// The service and the phase being called can be obtained by CallInfoFrom.
+type Middleware func(next PhaseFunc) PhaseFunc
+
+// WithMiddleware sets middlewares wrapping every validate, try, confirm and cancel call of the director.
+// The first one is the outermost. Every retry of confirm and cancel is wrapped again.
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(d *director) {
+		d.middlewares = append(d.middlewares, middlewares...)
+	}
+}
+
+// CallInfo describes a phase call.
+type CallInfo struct {
+	TxId        string
+	ServiceName string
+	Phase       Phase
+
+	// Isolation is the isolation hint of the service, which should be passed to the participant.
+	Isolation Isolation
+}
+
+type callInfoKey struct{}
+
+// CallInfoFrom returns CallInfo of the phase call ctx is passed to.
+func CallInfoFrom(ctx context.Context) (CallInfo, bool) {
+	info, ok := ctx.Value(callInfoKey{}).(CallInfo)
+	return info, ok
+}
+
+// wrap returns f wrapped by the middlewares of the director.
+func (d *director) wrap(f PhaseFunc) PhaseFunc {
+	for i := len(d.middlewares) - 1; i >= 0; i-- {
+		f = d.middlewares[i](f)
+	}
+	return f
+}
@@ -0,0 +1,62 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next PhaseFunc) PhaseFunc {
+			return func(ctx context.Context) error {
+				info, _ := CallInfoFrom(ctx)
+				calls = append(calls, fmt.Sprintf("%s %s %s", name, info.Phase, info.ServiceName))
+				return next(ctx)
+			}
+		}
+	}
+	d := NewDirector([]*Service{
+		NewService(
+			"s1",
+			func() error { calls = append(calls, "try"); return nil },
+			func() error { calls = append(calls, "confirm"); return nil },
+			func() error { return nil },
+		),
+	}, WithMiddleware(record("outer"), record("inner")))
+	if err := d.Direct(); err != nil {
+		t.Fatalf("director.Direct() error = %v", err)
+	}
+	want := []string{
+		"outer try s1", "inner try s1", "try",
+		"outer confirm s1", "inner confirm s1", "confirm",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestWithMiddleware_ShortCircuit(t *testing.T) {
+	errDenied := errors.New("denied")
+	var tried bool
+	d := NewDirector([]*Service{
+		NewService("s1", func() error { tried = true; return nil }, func() error { return nil }, func() error { return nil }),
+	}, WithMiddleware(func(next PhaseFunc) PhaseFunc {
+		return func(ctx context.Context) error { return errDenied }
+	}))
+	if err := d.Direct(); !errors.Is(err, errDenied) {
+		t.Errorf("director.Direct() error = %v, want %v", err, errDenied)
+	}
+	if tried {
+		t.Errorf("try is called through middleware which denied it")
+	}
+}
+
+func TestCallInfoFrom(t *testing.T) {
+	if _, ok := CallInfoFrom(context.Background()); ok {
+		t.Errorf("CallInfoFrom() of context not passed to phase call ok = true")
+	}
+}
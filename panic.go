@@ -0,0 +1,51 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the cause of *Error with ErrPanicked when a phase function panicked.
+type PanicError struct {
+	value interface{}
+	stack []byte
+}
+
+// Value returns the value passed to panic.
+func (e *PanicError) Value() interface{} {
+	return e.value
+}
+
+// Stack returns the stack trace of the goroutine when it panicked.
+func (e *PanicError) Stack() []byte {
+	return e.stack
+}
+
+// Error satisfies error interface
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// recoverPanic returns f which returns *PanicError instead of panicking.
+// It is never retried, because the same call will likely panic again.
+func recoverPanic(f PhaseFunc) PhaseFunc {
+	return func(ctx context.Context) (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = WithCode(&PanicError{value: v, stack: debug.Stack()}, CodePermanent)
+			}
+		}()
+		return f(ctx)
+	}
+}
+
+// failedPhase returns ErrPanicked if err is caused by a panic, or phase otherwise.
+func failedPhase(phase int, err error) int {
+	var e *PanicError
+	if errors.As(err, &e) {
+		return ErrPanicked
+	}
+	return phase
+}
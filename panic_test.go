@@ -0,0 +1,57 @@
+package tcc
+
+import (
+	"errors"
+	"testing"
+)
+
+func Test_director_Direct_Panic(t *testing.T) {
+	tests := []struct {
+		name         string
+		try, confirm func() error
+		wantCanceled bool
+		wantStatus   Status
+	}{
+		{
+			name:         "try panicked",
+			try:          func() error { panic("boom") },
+			confirm:      func() error { return nil },
+			wantCanceled: true,
+			wantStatus:   StatusCanceled,
+		},
+		{
+			name:       "confirm panicked",
+			try:        func() error { return nil },
+			confirm:    func() error { panic("boom") },
+			wantStatus: StatusFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var canceled bool
+			confirmCalls := 0
+			tx := NewTransaction([]*Service{
+				NewService("s1", tt.try, func() error { confirmCalls++; return tt.confirm() }, func() error { return nil }),
+				NewService("s2", func() error { return nil }, func() error { return nil }, func() error { canceled = true; return nil }),
+			})
+			err := tx.Wait()
+			var e *Error
+			if !errors.As(err, &e) || e.FailedPhase() != ErrPanicked || e.ServiceName() != "s1" {
+				t.Fatalf("Transaction.Wait() error = %v, want ErrPanicked of s1", err)
+			}
+			var pe *PanicError
+			if !errors.As(err, &pe) || pe.Value() != "boom" || len(pe.Stack()) == 0 {
+				t.Errorf("Transaction.Wait() error = %v, want PanicError", err)
+			}
+			if canceled != tt.wantCanceled {
+				t.Errorf("canceled = %v, want %v", canceled, tt.wantCanceled)
+			}
+			if got := tx.Status(); got != tt.wantStatus {
+				t.Errorf("Transaction.Status() = %v, want %v", got, tt.wantStatus)
+			}
+			if confirmCalls > 1 {
+				t.Errorf("panicking confirm is retried %d times", confirmCalls-1)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package tcc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RecoveryLimiter limits the rate at which Recover re-drives confirm and cancel of each service by a token bucket,
+// so that recovering many transactions after an outage does not knock the participant over again.
+// The rate is per service name, and can be shared by Recover of multiple stores.
+type RecoveryLimiter struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	fallback recoveryRate
+	rates    map[string]recoveryRate
+	buckets  map[string]*tokenBucket
+}
+
+type recoveryRate struct {
+	perSecond float64
+	burst     int
+}
+
+// NewRecoveryLimiter returns RecoveryLimiter which re-drives every service at most perSecond times per second,
+// bursting to burst. perSecond <= 0 means no limit except for services set by SetRate.
+func NewRecoveryLimiter(perSecond float64, burst int) *RecoveryLimiter {
+	return &RecoveryLimiter{
+		now:      time.Now,
+		fallback: recoveryRate{perSecond: perSecond, burst: burst},
+		rates:    map[string]recoveryRate{},
+		buckets:  map[string]*tokenBucket{},
+	}
+}
+
+// SetRate sets the rate of the service, e.g. lower for a fragile dependency.
+// perSecond <= 0 means the service is not limited.
+func (l *RecoveryLimiter) SetRate(name string, perSecond float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rates[name] = recoveryRate{perSecond: perSecond, burst: burst}
+	delete(l.buckets, name)
+}
+
+// WithRecoveryLimiter sets RecoveryLimiter which Recover waits for before re-driving each service of a transaction.
+// It has no effect on transactions directed by Direct.
+func WithRecoveryLimiter(l *RecoveryLimiter) Option {
+	return func(d *director) {
+		d.recovery = l
+	}
+}
+
+// wait waits for a token of every named service, or returns error when ctx is done.
+func (l *RecoveryLimiter) wait(ctx context.Context, names []string) error {
+	if l == nil {
+		return nil
+	}
+	for _, name := range names {
+		delay := l.reserve(name)
+		if delay <= 0 {
+			continue
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// reserve takes a token of the service, and returns how long to wait until it is available.
+func (l *RecoveryLimiter) reserve(name string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[name]
+	if !ok {
+		rate, ok := l.rates[name]
+		if !ok {
+			rate = l.fallback
+		}
+		if rate.perSecond <= 0 {
+			return 0
+		}
+		burst := float64(rate.burst)
+		if burst < 1 {
+			burst = 1
+		}
+		b = &tokenBucket{perSecond: rate.perSecond, burst: burst, tokens: burst, last: l.now()}
+		l.buckets[name] = b
+	}
+	return b.reserve(l.now())
+}
+
+// tokenBucket holds up to burst tokens, refilled perSecond per second.
+// tokens go negative by reservations waiting for refill.
+type tokenBucket struct {
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.tokens += now.Sub(b.last).Seconds() * b.perSecond
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.perSecond * float64(time.Second))
+}
+
+// redriven returns names of services whose confirm or cancel is re-driven by recovering state.
+func redriven(state *TxState) []string {
+	var names []string
+	for _, ss := range state.Services {
+		done := ss.CancelSucceeded
+		if state.Status == StatusConfirming {
+			done = ss.ConfirmSucceeded
+		}
+		if !done {
+			names = append(names, ss.Name)
+		}
+	}
+	return names
+}
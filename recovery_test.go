@@ -0,0 +1,87 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRecoveryLimiter_reserve(t *testing.T) {
+	l := NewRecoveryLimiter(2, 2)
+	l.SetRate("slow", 1, 1)
+	l.SetRate("free", 0, 0)
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+	tests := []struct {
+		name    string
+		advance time.Duration
+		want    time.Duration
+	}{
+		{name: "s1", want: 0},
+		{name: "s1", want: 0},
+		{name: "s1", want: 500 * time.Millisecond},
+		{name: "s1", want: time.Second},
+		{name: "s1", advance: time.Second, want: 500 * time.Millisecond},
+		{name: "s2", want: 0},
+		{name: "slow", want: 0},
+		{name: "slow", want: time.Second},
+		{name: "free", want: 0},
+		{name: "free", want: 0},
+	}
+	for i, tt := range tests {
+		now = now.Add(tt.advance)
+		if got := l.reserve(tt.name); got != tt.want {
+			t.Errorf("%d: reserve(%s) = %v, want %v", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithRecoveryLimiter(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, id := range []string{"tx1", "tx2", "tx3"} {
+		err := store.SaveTxState(ctx, &TxState{TxId: id, Status: StatusConfirming, Services: []ServiceState{
+			{Name: "s1", TrySucceeded: true},
+			{Name: "s2", TrySucceeded: true, ConfirmSucceeded: true},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := NewRecoveryLimiter(0, 0)
+	l.SetRate("s1", 20, 1)
+	var confirmed []time.Time
+	services := []*Service{
+		NewService("s1", func() error { return nil }, func() error { confirmed = append(confirmed, time.Now()); return nil }, func() error { return nil }),
+		NewService("s2", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+	}
+	start := time.Now()
+	if err := Recover(ctx, store, services, WithRecoveryLimiter(l)); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if len(confirmed) != 3 {
+		t.Fatalf("confirmed %d times, want 3", len(confirmed))
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Recover() took %v, want at least 100ms for 3 transactions at 20/s with burst 1", elapsed)
+	}
+
+	// s2 is not limited, and is confirmed already
+	err := store.SaveTxState(ctx, &TxState{TxId: "tx4", Status: StatusConfirming, Services: []ServiceState{
+		{Name: "s1", TrySucceeded: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SetRate("s1", 0.001, 1)
+	l.reserve("s1")
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := Recover(ctx, store, services, WithRecoveryLimiter(l)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Recover() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if len(confirmed) != 3 {
+		t.Errorf("confirmed %d times while waiting for the limiter, want 3", len(confirmed))
+	}
+}
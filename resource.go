@@ -0,0 +1,168 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+// ErrResourceBusy is returned when a resource key declared by a service
+// is locked by another in-flight transaction.
+var ErrResourceBusy = errors.New("resource is locked by another transaction")
+
+// ResourceLocker locks resource keys declared by services,
+// so that transactions touching the same resource are never in flight at the same time.
+type ResourceLocker interface {
+	// Lock locks all the keys for the transaction, or none of them.
+	// Locking keys already locked by the same transaction succeeds.
+	// If a key is locked by another transaction, ErrResourceBusy is returned.
+	Lock(txId string, keys []string) error
+
+	// Unlock releases the keys locked by the transaction.
+	// Keys which are not locked by the transaction are ignored.
+	Unlock(txId string, keys []string) error
+}
+
+// ResourceLockRenewer is ResourceLocker whose locks expire unless they are renewed while the transaction is in flight.
+// The director stops the renewal when it returns, so locks kept by a transaction ending StatusFailed expire.
+type ResourceLockRenewer interface {
+	ResourceLocker
+
+	// LockUntil locks the keys like Lock, but never keeps or renews them beyond deadline.
+	// The director calls it instead of Lock if the context of the transaction has a deadline.
+	LockUntil(txId string, keys []string, deadline time.Time) error
+
+	// StopRenewal stops renewing the keys locked by the transaction without unlocking them.
+	StopRenewal(txId string)
+}
+
+// WithResourceKeys declares keys of resources the service reserves in try phase.
+// They are locked by director's ResourceLocker before try phase, and released when the transaction
+// is confirmed or canceled. A transaction ending StatusFailed keeps them until Resume finishes it,
+// or until they expire if the locker is ResourceLockRenewer.
+func WithResourceKeys(keys ...string) ServiceOption {
+	return func(s *Service) {
+		s.resourceKeys = append(s.resourceKeys, keys...)
+	}
+}
+
+// WithResourceLocker sets ResourceLocker to detect transactions touching the same resource.
+// By default a transaction fails fast with ErrResourceBusy, use WithResourceLockWait to queue it instead.
+func WithResourceLocker(l ResourceLocker) Option {
+	return func(d *director) {
+		d.locker = l
+	}
+}
+
+// WithResourceLockWait makes the transaction wait for busy resources,
+// retrying to lock them with a policy returned by newBackOff until it stops.
+// newBackOff is called for every transaction, so that transactions sharing the option never share the state of a policy.
+// Use a backoff with max elapsed time or max retries to bound the wait.
+func WithResourceLockWait(newBackOff func() backoff.BackOff) Option {
+	return func(d *director) {
+		d.lockWait = newBackOff
+	}
+}
+
+type localResourceLocker struct {
+	owners map[string]string
+	mu     sync.Mutex
+}
+
+// NewLocalResourceLocker returns ResourceLocker which locks keys in this process.
+func NewLocalResourceLocker() ResourceLocker {
+	return &localResourceLocker{owners: map[string]string{}}
+}
+
+func (l *localResourceLocker) Lock(txId string, keys []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if owner, ok := l.owners[key]; ok && owner != txId {
+			return ErrResourceBusy
+		}
+	}
+	for _, key := range keys {
+		l.owners[key] = txId
+	}
+	return nil
+}
+
+func (l *localResourceLocker) Unlock(txId string, keys []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if l.owners[key] == txId {
+			delete(l.owners, key)
+		}
+	}
+	return nil
+}
+
+// lockResources locks resource keys of all the services.
+// The transaction never holds some keys while waiting for others:
+// if any key is busy, every key locked so far is released before waiting,
+// so transactions locking overlapping keys in different orders cannot deadlock each other.
+func (d *director) lockResources(ctx context.Context) error {
+	if d.locker == nil {
+		return nil
+	}
+	deadline, _ := ctx.Deadline()
+	if d.lockWait == nil {
+		return d.lockAll(deadline)
+	}
+	return backoff.Retry(func() error {
+		err := d.lockAll(deadline)
+		if err != nil && !errors.Is(err, ErrResourceBusy) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(d.lockWait(), ctx))
+}
+
+// lockAll locks resource keys of all the services, until deadline unless it is zero.
+func (d *director) lockAll(deadline time.Time) error {
+	renewer, renewing := d.locker.(ResourceLockRenewer)
+	for _, s := range d.services {
+		if len(s.resourceKeys) == 0 {
+			continue
+		}
+		lock := d.locker.Lock
+		if renewing && !deadline.IsZero() {
+			lock = func(txId string, keys []string) error { return renewer.LockUntil(txId, keys, deadline) }
+		}
+		if err := lock(d.txId, s.resourceKeys); err != nil {
+			d.unlockResources()
+			if !errors.Is(err, ErrResourceBusy) {
+				err = &CoordinatorError{op: "lock resources", err: err}
+			}
+			return &Error{
+				failedPhase: ErrTryFailed,
+				err:         err,
+				serviceName: s.name,
+			}
+		}
+	}
+	return nil
+}
+
+func (d *director) unlockResources() {
+	if d.locker == nil {
+		return
+	}
+	for _, s := range d.services {
+		if len(s.resourceKeys) > 0 {
+			_ = d.locker.Unlock(d.txId, s.resourceKeys)
+		}
+	}
+}
+
+// stopRenewal stops renewing the resource keys of the transaction, if the locker renews them.
+func (d *director) stopRenewal() {
+	if renewer, ok := d.locker.(ResourceLockRenewer); ok {
+		renewer.StopRenewal(d.txId)
+	}
+}
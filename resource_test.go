@@ -0,0 +1,320 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+func Test_localResourceLocker_Lock(t *testing.T) {
+	tests := []struct {
+		name    string
+		locked  map[string]string
+		txId    string
+		keys    []string
+		wantErr error
+	}{
+		{
+			name:    "free",
+			txId:    "tx1",
+			keys:    []string{"k1", "k2"},
+			wantErr: nil,
+		},
+		{
+			name:    "locked by same transaction",
+			locked:  map[string]string{"k1": "tx1"},
+			txId:    "tx1",
+			keys:    []string{"k1", "k2"},
+			wantErr: nil,
+		},
+		{
+			name:    "locked by other transaction",
+			locked:  map[string]string{"k2": "tx2"},
+			txId:    "tx1",
+			keys:    []string{"k1", "k2"},
+			wantErr: ErrResourceBusy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLocalResourceLocker().(*localResourceLocker)
+			for key, owner := range tt.locked {
+				l.owners[key] = owner
+			}
+			err := l.Lock(tt.txId, tt.keys)
+			if err != tt.wantErr {
+				t.Errorf("localResourceLocker.Lock() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, key := range tt.keys {
+				_, locked := tt.locked[key]
+				if err == nil && l.owners[key] != tt.txId {
+					t.Errorf("key %v is not locked by %v", key, tt.txId)
+				}
+				if err != nil && !locked && l.owners[key] != "" {
+					t.Errorf("key %v is locked partially", key)
+				}
+			}
+		})
+	}
+}
+
+func Test_localResourceLocker_Unlock(t *testing.T) {
+	l := NewLocalResourceLocker().(*localResourceLocker)
+	l.owners = map[string]string{"k1": "tx1", "k2": "tx2"}
+	if err := l.Unlock("tx1", []string{"k1", "k2"}); err != nil {
+		t.Errorf("localResourceLocker.Unlock() error = %v", err)
+	}
+	if _, ok := l.owners["k1"]; ok {
+		t.Errorf("k1 is not released")
+	}
+	if l.owners["k2"] != "tx2" {
+		t.Errorf("k2 locked by other transaction is released")
+	}
+}
+
+func Test_director_Direct_ResourceLocker(t *testing.T) {
+	newServices := func(tried *bool) []*Service {
+		return []*Service{
+			NewService(
+				"s1",
+				func() error { *tried = true; return nil },
+				func() error { return nil },
+				func() error { return nil },
+				WithResourceKeys("item-1"),
+			),
+		}
+	}
+
+	t.Run("fails fast when resource is busy", func(t *testing.T) {
+		var tried bool
+		l := NewLocalResourceLocker()
+		_ = l.Lock("other", []string{"item-1"})
+		err := NewDirector(newServices(&tried), WithResourceLocker(l)).Direct()
+		if !errors.Is(err, ErrResourceBusy) {
+			t.Errorf("director.Direct() error = %v, want %v", err, ErrResourceBusy)
+		}
+		if tried {
+			t.Errorf("try() is called")
+		}
+	})
+
+	t.Run("waits for resource", func(t *testing.T) {
+		var tried bool
+		l := NewLocalResourceLocker()
+		_ = l.Lock("other", []string{"item-1"})
+		time.AfterFunc(20*time.Millisecond, func() { _ = l.Unlock("other", []string{"item-1"}) })
+		err := NewDirector(
+			newServices(&tried),
+			WithResourceLocker(l),
+			WithResourceLockWait(func() backoff.BackOff { return backoff.NewConstantBackOff(5 * time.Millisecond) }),
+		).Direct()
+		if err != nil {
+			t.Errorf("director.Direct() error = %v", err)
+		}
+		if !tried {
+			t.Errorf("try() is not called")
+		}
+	})
+
+	t.Run("releases resource after transaction", func(t *testing.T) {
+		var tried bool
+		l := NewLocalResourceLocker()
+		if err := NewDirector(newServices(&tried), WithResourceLocker(l)).Direct(); err != nil {
+			t.Errorf("director.Direct() error = %v", err)
+		}
+		if err := l.Lock("other", []string{"item-1"}); err != nil {
+			t.Errorf("resource is not released: %v", err)
+		}
+	})
+
+	t.Run("keeps resource of failed transaction until resumed", func(t *testing.T) {
+		l := NewLocalResourceLocker()
+		failCancel := true
+		tx := NewTransaction([]*Service{
+			NewService("s1", func() error { return nil }, func() error { return nil },
+				func() error {
+					if failCancel {
+						return errors.New("cancel failed")
+					}
+					return nil
+				},
+				WithResourceKeys("item-1")),
+			NewService("s2", func() error { return errors.New("try failed") }, func() error { return nil }, func() error { return nil }),
+		}, WithResourceLocker(l), WithMaxRetries(0))
+		if err := tx.Wait(); err == nil {
+			t.Fatalf("transaction.Wait() error = nil")
+		}
+		if err := l.Lock("other", []string{"item-1"}); !errors.Is(err, ErrResourceBusy) {
+			t.Errorf("resource of failed transaction is released: %v", err)
+		}
+		failCancel = false
+		if err := tx.Resume(); err != nil {
+			t.Fatalf("transaction.Resume() error = %v", err)
+		}
+		if err := l.Lock("other", []string{"item-1"}); err != nil {
+			t.Errorf("resource is not released after resume: %v", err)
+		}
+	})
+}
+
+// barrierLocker lets every transaction lock its first keys before any of them continues.
+type barrierLocker struct {
+	ResourceLocker
+	first   sync.Map
+	arrived sync.WaitGroup
+}
+
+func (l *barrierLocker) Lock(txId string, keys []string) error {
+	err := l.ResourceLocker.Lock(txId, keys)
+	if _, loaded := l.first.LoadOrStore(txId, true); !loaded {
+		l.arrived.Done()
+		l.arrived.Wait()
+	}
+	return err
+}
+
+func Test_director_Direct_ResourceLocker_NoDeadlock(t *testing.T) {
+	l := &barrierLocker{ResourceLocker: NewLocalResourceLocker()}
+	l.arrived.Add(2)
+	newBackOff := func() backoff.BackOff {
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = time.Millisecond
+		return b
+	}
+	newDirector := func(first, second string) Director {
+		return NewDirector([]*Service{
+			NewService(
+				first,
+				func() error { return nil },
+				func() error { return nil },
+				func() error { return nil },
+				WithResourceKeys(first),
+			),
+			NewService(
+				second,
+				func() error { return nil },
+				func() error { return nil },
+				func() error { return nil },
+				WithResourceKeys(second),
+			),
+		}, WithResourceLocker(l), WithResourceLockWait(newBackOff))
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- newDirector("a", "b").Direct() }()
+	go func() { errs <- newDirector("b", "a").Direct() }()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != nil {
+				t.Errorf("director.Direct() error = %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("transactions locking in different orders are deadlocked")
+		}
+	}
+}
+
+func Test_director_Direct_ResourceLockWait_Coordinator(t *testing.T) {
+	l := NewLocalResourceLocker()
+	// every transaction waits for the same key, with a policy of its own made from the shared options
+	c := NewCoordinator(4, 8, WithResourceLocker(l), WithResourceLockWait(func() backoff.BackOff {
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = time.Millisecond
+		b.MaxInterval = 5 * time.Millisecond
+		return b
+	}))
+	var handles []TxHandle
+	for i := 0; i < 8; i++ {
+		h, err := c.Submit(NewService("s1",
+			func() error { time.Sleep(time.Millisecond); return nil },
+			func() error { return nil },
+			func() error { return nil },
+			WithResourceKeys("item-1")))
+		if err != nil {
+			t.Fatalf("Coordinator.Submit() error = %v", err)
+		}
+		handles = append(handles, h)
+	}
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Coordinator.Shutdown() error = %v", err)
+	}
+	for _, h := range handles {
+		if err := h.Wait(); err != nil {
+			t.Errorf("transaction %s error = %v", h.TxId(), err)
+		}
+	}
+}
+
+// renewingLocker records the deadlines and stopped renewals of transactions.
+type renewingLocker struct {
+	ResourceLocker
+	deadlines []time.Time
+	stopped   []string
+}
+
+func (l *renewingLocker) LockUntil(txId string, keys []string, deadline time.Time) error {
+	l.deadlines = append(l.deadlines, deadline)
+	return l.ResourceLocker.Lock(txId, keys)
+}
+
+func (l *renewingLocker) StopRenewal(txId string) {
+	l.stopped = append(l.stopped, txId)
+}
+
+func Test_director_Direct_ResourceLockRenewer(t *testing.T) {
+	tests := []struct {
+		name       string
+		confirmErr error
+		wantStatus Status
+		wantLocked bool
+	}{
+		{
+			name:       "confirmed",
+			wantStatus: StatusConfirmed,
+		},
+		{
+			name:       "failed",
+			confirmErr: errors.New("test"),
+			wantStatus: StatusFailed,
+			wantLocked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &renewingLocker{ResourceLocker: NewLocalResourceLocker()}
+			d := NewDirector([]*Service{
+				NewService(
+					"s1",
+					func() error { return nil },
+					func() error { return tt.confirmErr },
+					func() error { return nil },
+					WithResourceKeys("item-1"),
+				),
+			}, WithResourceLocker(l), WithMaxRetries(0))
+			deadline := time.Now().Add(time.Minute)
+			ctx, cancel := context.WithDeadline(context.Background(), deadline)
+			defer cancel()
+			_ = d.DirectContext(ctx)
+			if got := d.State().Status; got != tt.wantStatus {
+				t.Fatalf("director.State().Status = %v, want %v", got, tt.wantStatus)
+			}
+			if len(l.deadlines) != 1 || !l.deadlines[0].Equal(deadline) {
+				t.Errorf("locked until %v, want %v", l.deadlines, deadline)
+			}
+			// renewal stops whatever the status, so keys kept by the failed transaction expire
+			if len(l.stopped) != 1 || l.stopped[0] != d.TxId() {
+				t.Errorf("renewal stopped for %v, want %v", l.stopped, d.TxId())
+			}
+			locked := errors.Is(l.Lock("other", []string{"item-1"}), ErrResourceBusy)
+			if locked != tt.wantLocked {
+				t.Errorf("resource is locked = %v, want %v", locked, tt.wantLocked)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package tcc
+
+import "context"
+
+// Result is the outcome of a transaction returned by DirectResult.
+type Result struct {
+	// Status is the final status of the transaction.
+	Status Status
+
+	// TryErr is why the transaction was canceled instead of confirmed,
+	// e.g. the error of a failed try, or nil if it was not canceled.
+	TryErr error
+
+	// CancelErr is the error of cancel phase, or nil if every tried service was canceled.
+	CancelErr error
+
+	// ConfirmErr is the error of confirm phase, or nil if every service was confirmed.
+	ConfirmErr error
+
+	// Err is the error DirectContext returns.
+	// When cancel phase failed it is CancelErr, which hides TryErr.
+	Err error
+
+	// Hints guess why each failed service failed, when the transaction failed.
+	Hints []Hint
+}
+
+func (d *director) DirectResult(ctx context.Context) *Result {
+	err := d.DirectContext(ctx)
+	r := &Result{Status: d.Status(), TryErr: d.tryErr, Err: err}
+	switch {
+	case r.Status == StatusCanceled && r.TryErr == nil:
+		// refused before try phase
+		r.TryErr = err
+	case r.Status == StatusFailed && d.confirmStarted:
+		r.ConfirmErr = err
+	case r.Status == StatusFailed:
+		r.CancelErr = err
+	}
+	if r.Status == StatusFailed {
+		r.Hints = d.hints(err)
+	}
+	return r
+}
+
+func (d *director) DirectAsync(ctx context.Context) <-chan *Result {
+	results := make(chan *Result, 1)
+	go func() {
+		results <- d.DirectResult(ctx)
+		close(results)
+	}()
+	return results
+}
@@ -0,0 +1,132 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_director_DirectResult(t *testing.T) {
+	errTry, errConfirm, errCancel := errors.New("try"), errors.New("confirm"), errors.New("cancel")
+	tests := []struct {
+		name                string
+		try, confirm        error
+		cancel              error
+		validate            error
+		wantStatus          Status
+		wantTry, wantCancel error
+		wantConfirm         error
+	}{
+		{
+			name:       "confirmed",
+			wantStatus: StatusConfirmed,
+		},
+		{
+			name:       "canceled",
+			try:        errTry,
+			wantStatus: StatusCanceled,
+			wantTry:    errTry,
+		},
+		{
+			name:       "cancel failed",
+			try:        errTry,
+			cancel:     errCancel,
+			wantStatus: StatusFailed,
+			wantTry:    errTry,
+			wantCancel: errCancel,
+		},
+		{
+			name:        "confirm failed",
+			confirm:     errConfirm,
+			wantStatus:  StatusFailed,
+			wantConfirm: errConfirm,
+		},
+		{
+			name:       "refused before try",
+			validate:   errTry,
+			wantStatus: StatusCanceled,
+			wantTry:    errTry,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDirector([]*Service{
+				NewService(
+					"s1",
+					func() error { return nil },
+					func() error { return tt.confirm },
+					func() error { return tt.cancel },
+					WithValidate(func() error { return tt.validate }),
+				),
+				NewService(
+					"s2",
+					func() error { return tt.try },
+					func() error { return nil },
+					func() error { return nil },
+				),
+			}, WithMaxRetries(0))
+			r := d.DirectResult(context.Background())
+			if r.Status != tt.wantStatus {
+				t.Errorf("Result.Status = %v, want %v", r.Status, tt.wantStatus)
+			}
+			for _, c := range []struct {
+				field     string
+				got, want error
+			}{
+				{"TryErr", r.TryErr, tt.wantTry},
+				{"CancelErr", r.CancelErr, tt.wantCancel},
+				{"ConfirmErr", r.ConfirmErr, tt.wantConfirm},
+			} {
+				if !errors.Is(c.got, c.want) || (c.got == nil) != (c.want == nil) {
+					t.Errorf("Result.%s = %v, want %v", c.field, c.got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_director_DirectAsync(t *testing.T) {
+	release := make(chan struct{})
+	d := NewDirector([]*Service{
+		NewService("s1", func() error { <-release; return nil }, func() error { return nil }, func() error { return nil }),
+	})
+	results := d.DirectAsync(context.Background())
+	select {
+	case <-results:
+		t.Fatal("director.DirectAsync() finished before try returned")
+	default:
+	}
+	close(release)
+	r := <-results
+	if r.Err != nil || r.Status != StatusConfirmed {
+		t.Errorf("director.DirectAsync() = %+v, want confirmed", r)
+	}
+	if _, ok := <-results; ok {
+		t.Error("channel of director.DirectAsync() is not closed")
+	}
+}
+
+func Test_director_DirectResult_Hints(t *testing.T) {
+	health := NewHealthRegistry()
+	d := NewDirector([]*Service{
+		NewServiceContext("s1", func(context.Context) error {
+			health.StartDraining("s1")
+			return nil
+		}, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, func(context.Context) error { return nil }, WithConfirmTimeout(time.Millisecond)),
+		NewService("s2", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+	}, WithHealthRegistry(health), WithMaxRetries(2), WithDecorrelatedJitter(time.Millisecond, time.Millisecond))
+	r := d.DirectResult(context.Background())
+	if len(r.Hints) != 1 {
+		t.Fatalf("Result.Hints = %+v, want 1 hint", r.Hints)
+	}
+	h := r.Hints[0]
+	want := Hint{Service: "s1", FailedPhase: ErrConfirmFailed, Attempts: 3, TimedOut: true, Dependency: ErrDraining,
+		Message: "confirm of s1 failed after 3 attempts, timed out on every attempt, dependency is draining"}
+	if h != want {
+		t.Errorf("Result.Hints[0] = %+v, want %+v", h, want)
+	}
+}
@@ -0,0 +1,149 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+// maxRetryErrors is the number of last attempt errors kept in RetryError.
+const maxRetryErrors = 5
+
+// RecoveryAction is what has been done to the service after its retries are exhausted.
+type RecoveryAction int
+
+const (
+	// RecoveryManual means nothing will retry the service anymore,
+	// the inconsistent state needs to be fixed manually.
+	RecoveryManual RecoveryAction = iota
+
+	// RecoveryScheduled means the transaction is saved to the store,
+	// so the service is retried later by Recover.
+	RecoveryScheduled
+)
+
+func (a RecoveryAction) String() string {
+	switch a {
+	case RecoveryManual:
+		return "manual"
+	case RecoveryScheduled:
+		return "scheduled"
+	default:
+		return fmt.Sprintf("RecoveryAction(%d)", int(a))
+	}
+}
+
+// RetryError is the cause of *Error when confirm or cancel never succeeded after retries.
+// It knows the retry policy used, attempts made, last errors returned by the service,
+// and the recovery action taken, so that alert handlers can decide what to do next.
+type RetryError struct {
+	policy   backoff.BackOff
+	attempts int
+	errs     []error
+	action   RecoveryAction
+}
+
+// Policy returns backoff policy used to retry.
+func (e *RetryError) Policy() backoff.BackOff {
+	return e.policy
+}
+
+// Attempts returns how many times the service has been called.
+func (e *RetryError) Attempts() int {
+	return e.attempts
+}
+
+// Errors returns the last errors returned by the service, oldest first.
+func (e *RetryError) Errors() []error {
+	return e.errs
+}
+
+// RecoveryAction returns the recovery action taken after retries are exhausted.
+func (e *RetryError) RecoveryAction() RecoveryAction {
+	return e.action
+}
+
+// Error satisfies error interface
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts (%s): %s", e.attempts, e.action, e.Unwrap())
+}
+
+// Unwrap returns the last error returned by the service.
+func (e *RetryError) Unwrap() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return e.errs[len(e.errs)-1]
+}
+
+// WithServiceBackoff sets retry policy of confirm and cancel of the service,
+// instead of the one of the director made by WithMaxRetries,
+// e.g. to retry a flaky third-party participant more while a local database is retried once.
+// b limits the retries by itself, and is reset before each confirm or cancel.
+// b is shared by every service the option is passed to, and by copies of the service in concurrent transactions;
+// use WithServiceBackoffFactory to give each of them its own policy.
+func WithServiceBackoff(b backoff.BackOff) ServiceOption {
+	locked := &lockedBackOff{b: b}
+	return WithServiceBackoffFactory(func() backoff.BackOff { return locked })
+}
+
+// WithServiceBackoffFactory sets function returning a new retry policy of confirm and cancel of the service
+// like WithServiceBackoff. newBackOff is called for every confirm or cancel,
+// so that copies of the service in concurrent transactions never share the state of a policy.
+func WithServiceBackoffFactory(newBackOff func() backoff.BackOff) ServiceOption {
+	return func(s *Service) {
+		s.backoff = newBackOff
+	}
+}
+
+// WithRetryClassifier sets function which tells if an error of try, confirm or cancel is worth retrying,
+// instead of Code of the error, e.g. to fail fast on business errors like insufficient balance
+// while network errors keep retrying.
+func WithRetryClassifier(retryable func(err error) bool) Option {
+	return func(d *director) {
+		d.retryable = retryable
+	}
+}
+
+// retryableCode is the default retry classifier, which retries errors with retryable Code.
+func retryableCode(err error) bool {
+	return CodeOf(err).Retryable()
+}
+
+// classifyDirectorErrors makes retryable never retry errors made by director.
+func classifyDirectorErrors(retryable func(error) bool) func(error) bool {
+	return func(err error) bool {
+		var p *PanicError
+		if errors.Is(err, ErrCallLimitExceeded) || errors.As(err, &p) {
+			return false
+		}
+		return retryable(err)
+	}
+}
+
+// retry calls op until it succeeds, policy stops, ctx is done, or op returns error which is not retryable,
+// and returns *RetryError with the action if it never succeeded.
+func retry(ctx context.Context, op backoff.Operation, policy backoff.BackOff, retryable func(error) bool, action RecoveryAction) error {
+	e := &RetryError{policy: policy, action: action}
+	err := backoff.Retry(func() error {
+		e.attempts++
+		err := op()
+		if err == nil {
+			return nil
+		}
+		e.errs = append(e.errs, err)
+		if len(e.errs) > maxRetryErrors {
+			e.errs = e.errs[1:]
+		}
+		if !retryable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(policy, ctx))
+	if err != nil {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+func Test_retry(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRetries   uint64
+		failures     int
+		wantErr      bool
+		wantAttempts int
+		wantErrors   []string
+	}{
+		{
+			name:       "succeeded at first",
+			maxRetries: 3,
+			failures:   0,
+			wantErr:    false,
+		},
+		{
+			name:       "succeeded after retry",
+			maxRetries: 3,
+			failures:   2,
+			wantErr:    false,
+		},
+		{
+			name:         "retries exhausted",
+			maxRetries:   2,
+			failures:     10,
+			wantErr:      true,
+			wantAttempts: 3,
+			wantErrors:   []string{"1", "2", "3"},
+		},
+		{
+			name:         "keeps last errors only",
+			maxRetries:   6,
+			failures:     10,
+			wantErr:      true,
+			wantAttempts: 7,
+			wantErrors:   []string{"3", "4", "5", "6", "7"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var called int
+			op := func() error {
+				called++
+				if called <= tt.failures {
+					return fmt.Errorf("%d", called)
+				}
+				return nil
+			}
+			policy := backoff.WithMaxRetries(&backoff.ZeroBackOff{}, tt.maxRetries)
+			err := retry(context.Background(), op, policy, retryableCode, RecoveryManual)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("retry() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				return
+			}
+			var e *RetryError
+			if !errors.As(err, &e) {
+				t.Errorf("cannot cast to RetryError")
+				return
+			}
+			if e.Attempts() != tt.wantAttempts {
+				t.Errorf("RetryError.Attempts() = %v, want %v", e.Attempts(), tt.wantAttempts)
+			}
+			var got []string
+			for _, err := range e.Errors() {
+				got = append(got, err.Error())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantErrors) {
+				t.Errorf("RetryError.Errors() = %v, want %v", got, tt.wantErrors)
+			}
+			if e.Policy() != policy {
+				t.Errorf("RetryError.Policy() is not the policy used")
+			}
+			if e.RecoveryAction() != RecoveryManual {
+				t.Errorf("RetryError.RecoveryAction() = %v, want %v", e.RecoveryAction(), RecoveryManual)
+			}
+		})
+	}
+}
+
+func TestRetryError_RecoveryAction(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want RecoveryAction
+	}{
+		{name: "without store", want: RecoveryManual},
+		{name: "with store", opts: []Option{WithStore(NewMemoryStore())}, want: RecoveryScheduled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDirector([]*Service{
+				NewService("s1", func() error { return nil }, func() error { return errors.New("test") }, func() error { return nil }),
+			}, append(tt.opts, WithMaxRetries(0))...).Direct()
+			var e *RetryError
+			if !errors.As(err, &e) {
+				t.Fatalf("director.Direct() error = %v, want RetryError", err)
+			}
+			if e.RecoveryAction() != tt.want {
+				t.Errorf("RetryError.RecoveryAction() = %v, want %v", e.RecoveryAction(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWithServiceBackoff(t *testing.T) {
+	var flaky, local int
+	failing := func(calls *int) func() error {
+		return func() error {
+			*calls++
+			return errors.New("test")
+		}
+	}
+	d := NewDirector([]*Service{
+		NewService("flaky", func() error { return nil }, failing(&flaky), func() error { return nil },
+			WithServiceBackoff(backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 4))),
+		NewService("local", func() error { return nil }, failing(&local), func() error { return nil }),
+	}, WithMaxRetries(1))
+	if err := d.Direct(); err == nil {
+		t.Fatal("director.Direct() error = nil, want error")
+	}
+	if flaky != 5 || local != 2 {
+		t.Errorf("confirm of flaky and local called %v and %v times, want %v and %v", flaky, local, 5, 2)
+	}
+}
+
+func TestWithServiceBackoffFactory(t *testing.T) {
+	failing := func(calls *int) func() error {
+		return func() error {
+			*calls++
+			return errors.New("test")
+		}
+	}
+	var flaky, flaky2, local int
+	// the option is shared by services, but each of them gets its own policy
+	aggressive := WithServiceBackoffFactory(func() backoff.BackOff { return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 4) })
+	d := NewDirector([]*Service{
+		NewService("flaky", func() error { return nil }, failing(&flaky), func() error { return nil }, aggressive),
+		NewService("flaky2", func() error { return nil }, failing(&flaky2), func() error { return nil }, aggressive),
+		NewService("local", func() error { return nil }, failing(&local), func() error { return nil }),
+	}, WithMaxRetries(1), WithDeterministicSchedule(1))
+	if err := d.Direct(); err == nil {
+		t.Fatal("director.Direct() error = nil, want error")
+	}
+	if flaky != 5 || flaky2 != 5 {
+		t.Errorf("confirm of flaky services called %v and %v times, want %v", flaky, flaky2, 5)
+	}
+	if local != 2 {
+		t.Errorf("confirm of local called %v times, want %v", local, 2)
+	}
+}
@@ -1,25 +1,143 @@
 package tcc
 
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+// PhaseFunc is a function called in a phase of a service.
+// ctx is done when the caller of DirectContext gives up, or the transaction is aborted in try phase.
+type PhaseFunc func(ctx context.Context) error
+
+// TryFunc is a try function returning result, e.g. the ID of the reservation,
+// which is passed to confirm and cancel of the service.
+type TryFunc func(ctx context.Context) ([]byte, error)
+
+// ResultFunc is a confirm or cancel function receiving the result of try.
+type ResultFunc func(ctx context.Context, tryResult []byte) error
+
 // Service can be TCC service, which can Try(), Confirm(), and Cancel()
 type Service struct {
 	txId string
 	name string
 
-	try     func() error
-	confirm func() error
-	cancel  func() error
+	validate PhaseFunc
+	try      TryFunc
+	confirm  ResultFunc
+	cancel   ResultFunc
+
+	tryResult []byte
 
-	tried            bool
-	trySucceeded     bool
+	tried        bool
+	trySucceeded bool
+	// lateTry is set when try returned nil after its timeout, so it needs cancel although it failed.
+	lateTry          bool
 	confirmed        bool
 	confirmSucceeded bool
 	canceled         bool
 	cancelSucceeded  bool
+
+	resourceKeys []string
+	isolation    Isolation
+	limiter      limiter
+	timeouts     map[Phase]time.Duration
+	backoff      func() backoff.BackOff
+}
+
+// ServiceOption can set option to service
+type ServiceOption func(s *Service)
+
+// WithValidate sets validate function of the service.
+// Validate is called before any service's try, and should be a fast, side-effect-free check
+// such as stock lookup or balance check, so that obviously doomed transactions fail before reserving anything.
+func WithValidate(validate func() error) ServiceOption {
+	return WithValidateContext(withoutContext(validate))
+}
+
+// WithValidateContext sets validate function of the service, which receives context.
+func WithValidateContext(validate PhaseFunc) ServiceOption {
+	return func(s *Service) {
+		s.validate = validate
+	}
+}
+
+// Isolation is a hint to the participant about how to reserve resources in try phase.
+// Director never interprets it, participants choose their local strategy by it.
+type Isolation string
+
+const (
+	// IsolationDefault leaves the strategy to the participant.
+	IsolationDefault Isolation = ""
+
+	// IsolationLock asks the participant to reserve with row locks.
+	IsolationLock Isolation = "lock"
+
+	// IsolationOptimistic asks the participant to reserve optimistically, e.g. with version checks.
+	IsolationOptimistic Isolation = "optimistic"
+)
+
+// WithIsolation sets isolation hint of the service.
+func WithIsolation(isolation Isolation) ServiceOption {
+	return func(s *Service) {
+		s.isolation = isolation
+	}
 }
 
 // NewService returns service with passed functions
-func NewService(name string, try, confirm, cancel func() error) *Service {
-	return &Service{name: name, try: try, confirm: confirm, cancel: cancel}
+func NewService(name string, try, confirm, cancel func() error, opts ...ServiceOption) *Service {
+	return NewServiceContext(name, withoutContext(try), withoutContext(confirm), withoutContext(cancel), opts...)
+}
+
+// NewServiceContext returns service with passed functions, which receive context.
+// Use it with DirectContext so that deadlines and cancellation of the caller reach every phase.
+func NewServiceContext(name string, try, confirm, cancel PhaseFunc, opts ...ServiceOption) *Service {
+	return NewServiceWithResult(name, withoutResult(try), ignoreResult(confirm), ignoreResult(cancel), opts...)
+}
+
+// NewServiceWithResult returns service whose try returns result passed to confirm and cancel,
+// instead of keeping it in closures.
+// The result is saved to Store with the transaction, so that it is passed to services recovered by Recover.
+// If the process crashed in try phase, cancel of a recovered transaction can receive nil.
+func NewServiceWithResult(name string, try TryFunc, confirm, cancel ResultFunc, opts ...ServiceOption) *Service {
+	s := &Service{name: name, try: try, confirm: confirm, cancel: cancel}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func withoutContext(f func() error) PhaseFunc {
+	if f == nil {
+		return nil
+	}
+	return func(context.Context) error { return f() }
+}
+
+func withoutResult(f PhaseFunc) TryFunc {
+	if f == nil {
+		return nil
+	}
+	return func(ctx context.Context) ([]byte, error) { return nil, f(ctx) }
+}
+
+func ignoreResult(f PhaseFunc) ResultFunc {
+	if f == nil {
+		return nil
+	}
+	return func(ctx context.Context, _ []byte) error { return f(ctx) }
+}
+
+// Validate executes passed validate function, or returns nil if it is not set.
+func (s *Service) Validate() error { return s.ValidateContext(context.Background()) }
+
+// ValidateContext is Validate with context.
+func (s *Service) ValidateContext(ctx context.Context) error {
+	if s.validate == nil {
+		return nil
+	}
+	return s.validate(ctx)
 }
 
 // Try executes passed try function.
@@ -27,21 +145,46 @@ func NewService(name string, try, confirm, cancel func() error) *Service {
 // After try phase is finished successfully, Confirm called.
 // Try can fail, but if try succeeded, confirm must succeed.
 // If try fails, Cancel will be called.
-// Try never be retried.
-func (s *Service) Try() error { return s.try() }
+// Try is never retried unless WithTryRetries is set.
+func (s *Service) Try() error { return s.TryContext(context.Background()) }
+
+// TryContext is Try with context.
+func (s *Service) TryContext(ctx context.Context) error {
+	result, err := s.try(ctx)
+	if err == nil {
+		s.tryResult = result
+	}
+	return err
+}
+
+// TryResult returns the result of try which succeeded.
+func (s *Service) TryResult() []byte {
+	return s.tryResult
+}
 
 // Confirm executes passed confirm function.
 // In confirm phase, service will confirm things which is reserved in try phase.
 // Basically Confirm should never return error, except network or infrastructure issues.
 // This will be retried 10 times by default.
-func (s *Service) Confirm() error { return s.confirm() }
+func (s *Service) Confirm() error { return s.ConfirmContext(context.Background()) }
+
+// ConfirmContext is Confirm with context.
+func (s *Service) ConfirmContext(ctx context.Context) error { return s.confirm(ctx, s.tryResult) }
 
 // Cancel executes passed cancel function.
 // This will be called after Try phase failed.
 // In Cancel phase, service will revert the state which is changed by try phase.
 // Basically Confirm should never return error, except network or infrastructure issues.
 // This will be retried 10 times by default.
-func (s *Service) Cancel() error { return s.cancel() }
+func (s *Service) Cancel() error { return s.CancelContext(context.Background()) }
+
+// CancelContext is Cancel with context.
+func (s *Service) CancelContext(ctx context.Context) error { return s.cancel(ctx, s.tryResult) }
+
+// Isolation returns isolation hint of the service.
+func (s *Service) Isolation() Isolation {
+	return s.isolation
+}
 
 // Tried returns if the service try() called
 func (s *Service) Tried() bool {
@@ -55,20 +198,20 @@ func (s *Service) TrySucceeded() bool {
 
 // Confirmed returns if the service confirm() called
 func (s *Service) Confirmed() bool {
-	return s.tried
+	return s.confirmed
 }
 
 // ConfirmSucceeded returns if the service confirm() succeeded
 func (s *Service) ConfirmSucceeded() bool {
-	return s.tried
+	return s.confirmSucceeded
 }
 
 // Canceled returns if the service cancel() is called
 func (s *Service) Canceled() bool {
-	return s.tried
+	return s.canceled
 }
 
 // CancelSucceeded returns if the service cancel() succeeded
 func (s *Service) CancelSucceeded() bool {
-	return s.tried
+	return s.cancelSucceeded
 }
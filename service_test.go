@@ -1,6 +1,9 @@
 package tcc
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"testing"
 )
 
@@ -28,7 +31,7 @@ func TestService_Try(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Service{
-				try: tt.fields.try,
+				try: withoutResult(withoutContext(tt.fields.try)),
 			}
 			if err := s.Try(); (err != nil) != tt.wantErr {
 				t.Errorf("Service.Try() error = %v, wantErr %v", err, tt.wantErr)
@@ -64,7 +67,7 @@ func TestService_Confirm(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Service{
-				confirm: tt.fields.confirm,
+				confirm: ignoreResult(withoutContext(tt.fields.confirm)),
 			}
 			if err := s.Confirm(); (err != nil) != tt.wantErr {
 				t.Errorf("Service.Confirm() error = %v, wantErr %v", err, tt.wantErr)
@@ -100,7 +103,7 @@ func TestService_Cancel(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s := &Service{
-				cancel: tt.fields.cancel,
+				cancel: ignoreResult(withoutContext(tt.fields.cancel)),
 			}
 			if err := s.Cancel(); (err != nil) != tt.wantErr {
 				t.Errorf("Service.Cancel() error = %v, wantErr %v", err, tt.wantErr)
@@ -169,3 +172,71 @@ func TestService_Params(t *testing.T) {
 		})
 	}
 }
+
+func TestService_Isolation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ServiceOption
+		want Isolation
+	}{
+		{
+			name: "default",
+			want: IsolationDefault,
+		},
+		{
+			name: "lock",
+			opts: []ServiceOption{WithIsolation(IsolationLock)},
+			want: IsolationLock,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService("s1", nil, nil, nil, tt.opts...)
+			if got := s.Isolation(); got != tt.want {
+				t.Errorf("Service.Isolation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewServiceWithResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		tryErr error
+		want   map[string]string
+	}{
+		{
+			name: "confirm receives try result",
+			want: map[string]string{"confirm": "r1"},
+		},
+		{
+			name:   "cancel receives try result",
+			tryErr: errors.New("test"),
+			want:   map[string]string{"cancel": "r1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]string{}
+			record := func(phase string) ResultFunc {
+				return func(ctx context.Context, tryResult []byte) error {
+					got[phase] = string(tryResult)
+					return nil
+				}
+			}
+			d := NewDirector([]*Service{
+				NewServiceWithResult(
+					"s1",
+					func(context.Context) ([]byte, error) { return []byte("r1"), nil },
+					record("confirm"),
+					record("cancel"),
+				),
+				NewService("s2", func() error { return tt.tryErr }, func() error { return nil }, func() error { return nil }),
+			}, WithDeterministicSchedule(1))
+			_ = d.Direct()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("results = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
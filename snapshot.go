@@ -0,0 +1,126 @@
+package tcc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// SnapshotStore is Store which keeps states in memory like NewMemoryStore,
+// and snapshots them to a file periodically and on Close, restoring them on start.
+// It is a middle ground between no durability and running a database for lightweight services:
+// transactions saved after the last snapshot are lost by a crash.
+type SnapshotStore struct {
+	mem  *memoryStore
+	path string
+	// writing serializes snapshots
+	writing sync.Mutex
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSnapshotStore returns SnapshotStore restored from the snapshot at path if it exists,
+// which snapshots every interval. interval 0 means it snapshots only on Snapshot and Close.
+// Errors of periodic snapshots are ignored, the next one tries again.
+func NewSnapshotStore(path string, interval time.Duration) (*SnapshotStore, error) {
+	s := &SnapshotStore{
+		mem:  &memoryStore{txs: map[string]*TxState{}},
+		path: path,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.mem.txs); err != nil {
+			return nil, err
+		}
+	}
+	if interval <= 0 {
+		close(s.done)
+		return s, nil
+	}
+	go s.run(interval)
+	return s, nil
+}
+
+func (s *SnapshotStore) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			_ = s.Snapshot()
+		}
+	}
+}
+
+// Snapshot writes every state to the file, replacing the previous snapshot.
+func (s *SnapshotStore) Snapshot() error {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	s.mem.Lock()
+	data, err := json.Marshal(s.mem.txs)
+	s.mem.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// Close stops periodic snapshots and takes the last one, e.g. on shutdown.
+func (s *SnapshotStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	<-s.done
+	return s.Snapshot()
+}
+
+// SaveTxState implements Store.
+func (s *SnapshotStore) SaveTxState(ctx context.Context, state *TxState) error {
+	return s.mem.SaveTxState(ctx, state)
+}
+
+// MarkPhaseDone implements Store.
+func (s *SnapshotStore) MarkPhaseDone(ctx context.Context, txId, serviceName string, phase Phase) error {
+	return s.mem.MarkPhaseDone(ctx, txId, serviceName, phase)
+}
+
+// LoadPendingTx implements Store.
+func (s *SnapshotStore) LoadPendingTx(ctx context.Context) ([]*TxState, error) {
+	return s.mem.LoadPendingTx(ctx)
+}
+
+// GetTx implements Getter.
+func (s *SnapshotStore) GetTx(ctx context.Context, txId string) (*TxState, error) {
+	return s.mem.GetTx(ctx, txId)
+}
+
+// ClaimTx implements Claimer.
+func (s *SnapshotStore) ClaimTx(ctx context.Context, txId string) (*TxState, error) {
+	return s.mem.ClaimTx(ctx, txId)
+}
+
+// PendingStartTimes implements PendingInspector.
+func (s *SnapshotStore) PendingStartTimes(ctx context.Context) ([]time.Time, error) {
+	return s.mem.PendingStartTimes(ctx)
+}
+
+// ListTx implements Lister.
+func (s *SnapshotStore) ListTx(ctx context.Context, after string, limit int) ([]*TxState, string, error) {
+	return s.mem.ListTx(ctx, after, limit)
+}
+
+// CountByStatus implements StatusCounter.
+func (s *SnapshotStore) CountByStatus(ctx context.Context, since time.Time) (map[Status]int, error) {
+	return s.mem.CountByStatus(ctx, since)
+}
@@ -0,0 +1,66 @@
+package tcc
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewSnapshotStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	s, err := NewSnapshotStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+	state := &TxState{
+		TxId:      "tx1",
+		Status:    StatusCanceling,
+		StartedAt: time.Unix(100, 0).UTC(),
+		Services:  []ServiceState{{Name: "s1", TrySucceeded: true, TryResult: []byte("id")}},
+	}
+	if err := s.SaveTxState(ctx, state); err != nil {
+		t.Fatalf("SnapshotStore.SaveTxState() error = %v", err)
+	}
+	if err := s.SaveTxState(ctx, &TxState{TxId: "tx2", Status: StatusConfirmed}); err != nil {
+		t.Fatalf("SnapshotStore.SaveTxState() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("SnapshotStore.Close() error = %v", err)
+	}
+
+	restored, err := NewSnapshotStore(path, 0)
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+	got, err := restored.LoadPendingTx(ctx)
+	if err != nil {
+		t.Fatalf("SnapshotStore.LoadPendingTx() error = %v", err)
+	}
+	if want := []*TxState{state}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SnapshotStore.LoadPendingTx() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSnapshotStore_periodic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	s, err := NewSnapshotStore(path, time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewSnapshotStore() error = %v", err)
+	}
+	defer s.Close()
+	_ = s.SaveTxState(context.Background(), &TxState{TxId: "tx1", Status: StatusTrying})
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		restored, err := NewSnapshotStore(path, 0)
+		if err == nil {
+			if got, _ := restored.LoadPendingTx(context.Background()); len(got) == 1 {
+				return
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Error("transaction is not snapshotted periodically")
+}
@@ -0,0 +1,299 @@
+package tcc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// spoolRecord is a write to Store kept in the journal, either SaveTxState or MarkPhaseDone.
+type spoolRecord struct {
+	State       *TxState `json:"state,omitempty"`
+	TxId        string   `json:"txId,omitempty"`
+	ServiceName string   `json:"serviceName,omitempty"`
+	Phase       Phase    `json:"phase,omitempty"`
+}
+
+type spoolStore struct {
+	store Store
+	path  string
+	// spooled is true while the journal may have records, so that writes do not read the file otherwise.
+	spooled bool
+
+	sync.Mutex
+}
+
+// NewSpoolStore returns Store which writes to store, and when store fails,
+// appends the write to the local journal file at path instead and reports success,
+// so that transactions keep going during a brief outage of store.
+// The journal is replayed to store in order before every following call, and removed once it is replayed,
+// so writes reach store in the order they are made. A journal left by a crash is replayed after restart.
+// While the journal is not replayed, other processes sharing store see the transactions as of before the outage.
+// The returned Store implements Getter, Claimer, Lister, StatusCounter and PendingInspector,
+// which replay the journal and call store. Getter and Claimer fall back to Lister and LoadPendingTx of store,
+// the others fail if store does not implement them.
+func NewSpoolStore(store Store, path string) Store {
+	_, err := os.Stat(path)
+	return &spoolStore{store: store, path: path, spooled: !errors.Is(err, os.ErrNotExist)}
+}
+
+func (s *spoolStore) SaveTxState(ctx context.Context, state *TxState) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.write(ctx, spoolRecord{State: state})
+}
+
+func (s *spoolStore) MarkPhaseDone(ctx context.Context, txId, serviceName string, phase Phase) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.write(ctx, spoolRecord{TxId: txId, ServiceName: serviceName, Phase: phase})
+}
+
+func (s *spoolStore) LoadPendingTx(ctx context.Context) ([]*TxState, error) {
+	if err := s.replayed(ctx); err != nil {
+		return nil, err
+	}
+	return s.store.LoadPendingTx(ctx)
+}
+
+func (s *spoolStore) GetTx(ctx context.Context, txId string) (*TxState, error) {
+	if err := s.replayed(ctx); err != nil {
+		return nil, err
+	}
+	if getter, ok := s.store.(Getter); ok {
+		return getter.GetTx(ctx, txId)
+	}
+	if _, ok := s.store.(Lister); !ok {
+		return nil, fmt.Errorf("%T implements neither Getter nor Lister", s.store)
+	}
+	errFound := errors.New("found")
+	var found *TxState
+	err := EachTx(ctx, s.store, 100, func(state *TxState) error {
+		if state.TxId == txId {
+			found = state
+			return errFound
+		}
+		return nil
+	})
+	if found != nil {
+		return found, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, ErrTxNotFound
+}
+
+func (s *spoolStore) ClaimTx(ctx context.Context, txId string) (*TxState, error) {
+	if err := s.replayed(ctx); err != nil {
+		return nil, err
+	}
+	if claimer, ok := s.store.(Claimer); ok {
+		return claimer.ClaimTx(ctx, txId)
+	}
+	states, err := s.store.LoadPendingTx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		if state.TxId == txId {
+			return state, nil
+		}
+	}
+	return nil, ErrTxNotFound
+}
+
+func (s *spoolStore) ListTx(ctx context.Context, after string, limit int) ([]*TxState, string, error) {
+	lister, ok := s.store.(Lister)
+	if !ok {
+		return nil, "", fmt.Errorf("%T does not implement Lister", s.store)
+	}
+	if err := s.replayed(ctx); err != nil {
+		return nil, "", err
+	}
+	return lister.ListTx(ctx, after, limit)
+}
+
+func (s *spoolStore) CountByStatus(ctx context.Context, since time.Time) (map[Status]int, error) {
+	counter, ok := s.store.(StatusCounter)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement StatusCounter", s.store)
+	}
+	if err := s.replayed(ctx); err != nil {
+		return nil, err
+	}
+	return counter.CountByStatus(ctx, since)
+}
+
+func (s *spoolStore) PendingStartTimes(ctx context.Context) ([]time.Time, error) {
+	inspector, ok := s.store.(PendingInspector)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement PendingInspector", s.store)
+	}
+	if err := s.replayed(ctx); err != nil {
+		return nil, err
+	}
+	return inspector.PendingStartTimes(ctx)
+}
+
+// replayed replays the journal before reading store, so that reads see every write reported as saved.
+func (s *spoolStore) replayed(ctx context.Context) error {
+	s.Lock()
+	defer s.Unlock()
+	if err := s.replay(ctx); err != nil {
+		return fmt.Errorf("replay spooled writes: %w", err)
+	}
+	return nil
+}
+
+// write applies r to store, or appends it to the journal if the journal is not replayed or store fails.
+func (s *spoolStore) write(ctx context.Context, r spoolRecord) error {
+	if err := s.replay(ctx); err == nil {
+		err = s.apply(ctx, r)
+		if err == nil || errors.Is(err, ErrTxNotFound) {
+			return err
+		}
+	}
+	return s.append(r)
+}
+
+func (s *spoolStore) apply(ctx context.Context, r spoolRecord) error {
+	if r.State != nil {
+		return s.store.SaveTxState(ctx, r.State)
+	}
+	return s.store.MarkPhaseDone(ctx, r.TxId, r.ServiceName, r.Phase)
+}
+
+// append appends r to the journal as a line.
+// A torn record at the end of the journal is removed first, so that r starts on its own line,
+// and a failed append is removed so that it tears no record.
+func (s *spoolStore) append(r spoolRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	size, err := trimTornRecord(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if _, err := f.WriteAt(append(line, '\n'), size); err != nil {
+		_ = f.Truncate(size)
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Truncate(size)
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.spooled = true
+	return nil
+}
+
+// trimTornRecord truncates the journal after its last newline, and returns the size of the journal.
+// A record without newline was torn by a crash while appending, and was never reported as saved.
+func trimTornRecord(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	if size == 0 {
+		return 0, nil
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err != nil {
+		return 0, err
+	}
+	if last[0] == '\n' {
+		return size, nil
+	}
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil {
+		return 0, err
+	}
+	size = int64(bytes.LastIndexByte(data, '\n') + 1)
+	return size, f.Truncate(size)
+}
+
+// replay applies the journal to store in order, and keeps the records which are not applied.
+func (s *spoolStore) replay(ctx context.Context) error {
+	if !s.spooled {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.spooled = false
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	applied := 0
+	for applied < len(data) {
+		end := bytes.IndexByte(data[applied:], '\n')
+		if end < 0 {
+			// a record torn by a crash while appending is the last one without newline, and was never reported as saved
+			break
+		}
+		var r spoolRecord
+		if err := json.Unmarshal(data[applied:applied+end], &r); err != nil {
+			return s.truncate(data, applied, fmt.Errorf("corrupt record in %s: %w", s.path, err))
+		}
+		// ErrTxNotFound cannot succeed by retrying, so the record is dropped
+		if err := s.apply(ctx, r); err != nil && !errors.Is(err, ErrTxNotFound) {
+			return s.truncate(data, applied, err)
+		}
+		applied += end + 1
+	}
+	if err := os.Remove(s.path); err != nil {
+		return err
+	}
+	s.spooled = false
+	return nil
+}
+
+// truncate removes applied bytes from the head of the journal, and returns err.
+func (s *spoolStore) truncate(data []byte, applied int, err error) error {
+	if applied == 0 {
+		return err
+	}
+	if werr := writeFileAtomic(s.path, data[applied:]); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// writeFileAtomic replaces the file at path with data, so that a crash leaves either the old or the new file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
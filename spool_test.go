@@ -0,0 +1,183 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// downStore fails every call while down is true.
+type downStore struct {
+	Store
+	down bool
+}
+
+func (s *downStore) SaveTxState(ctx context.Context, state *TxState) error {
+	if s.down {
+		return errors.New("store is down")
+	}
+	return s.Store.SaveTxState(ctx, state)
+}
+
+func (s *downStore) MarkPhaseDone(ctx context.Context, txId, serviceName string, phase Phase) error {
+	if s.down {
+		return errors.New("store is down")
+	}
+	return s.Store.MarkPhaseDone(ctx, txId, serviceName, phase)
+}
+
+func (s *downStore) LoadPendingTx(ctx context.Context) ([]*TxState, error) {
+	if s.down {
+		return nil, errors.New("store is down")
+	}
+	return s.Store.LoadPendingTx(ctx)
+}
+
+func TestNewSpoolStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "spool")
+	store := &downStore{Store: NewMemoryStore(), down: true}
+	spool := NewSpoolStore(store, path)
+	state := &TxState{TxId: "tx1", Status: StatusConfirming, Services: []ServiceState{{Name: "s1", TrySucceeded: true}}}
+	if err := spool.SaveTxState(ctx, state); err != nil {
+		t.Fatalf("spoolStore.SaveTxState() error = %v", err)
+	}
+	if err := spool.MarkPhaseDone(ctx, "tx1", "s1", PhaseConfirm); err != nil {
+		t.Fatalf("spoolStore.MarkPhaseDone() error = %v", err)
+	}
+	if _, err := spool.LoadPendingTx(ctx); err == nil {
+		t.Fatal("spoolStore.LoadPendingTx() error = nil while store is down")
+	}
+
+	// a new spool store replays the journal left by the previous one
+	store.down = false
+	got, err := NewSpoolStore(store, path).LoadPendingTx(ctx)
+	if err != nil {
+		t.Fatalf("spoolStore.LoadPendingTx() error = %v", err)
+	}
+	want := []*TxState{{TxId: "tx1", Status: StatusConfirming, Services: []ServiceState{{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("spoolStore.LoadPendingTx() = %+v, want %+v", got, want)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("journal is not removed after replayed, os.Stat() error = %v", err)
+	}
+}
+
+func TestNewSpoolStore_Direct(t *testing.T) {
+	store := &downStore{Store: NewMemoryStore(), down: true}
+	d := NewDirector([]*Service{
+		NewService("s1", func() error { return nil }, func() error { return nil }, func() error { return nil }),
+	}, WithStore(NewSpoolStore(store, filepath.Join(t.TempDir(), "spool"))))
+	if err := d.Direct(); err != nil {
+		t.Errorf("director.Direct() error = %v while store is down", err)
+	}
+}
+
+func TestNewSpoolStore_optionalInterfaces(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "spool")
+	mem := NewMemoryStore()
+	state := &TxState{TxId: "tx1", Status: StatusConfirming, StartedAt: time.Now(), Services: []ServiceState{{Name: "s1", TrySucceeded: true}}}
+	if err := NewSpoolStore(&downStore{Store: mem, down: true}, path).SaveTxState(ctx, state); err != nil {
+		t.Fatalf("spoolStore.SaveTxState() error = %v", err)
+	}
+
+	// every read replays the journal first
+	spool := NewSpoolStore(mem, path)
+	if got, err := spool.(Getter).GetTx(ctx, "tx1"); err != nil || got.Status != StatusConfirming {
+		t.Errorf("spoolStore.GetTx() = %+v, %v, want the spooled transaction", got, err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("journal is not replayed by GetTx, os.Stat() error = %v", err)
+	}
+	if got, err := spool.(Claimer).ClaimTx(ctx, "tx1"); err != nil || got.TxId != "tx1" {
+		t.Errorf("spoolStore.ClaimTx() = %+v, %v", got, err)
+	}
+	if got, _, err := spool.(Lister).ListTx(ctx, "", 10); err != nil || len(got) != 1 {
+		t.Errorf("spoolStore.ListTx() = %+v, %v", got, err)
+	}
+	if got, err := spool.(StatusCounter).CountByStatus(ctx, time.Time{}); err != nil || got[StatusConfirming] != 1 {
+		t.Errorf("spoolStore.CountByStatus() = %v, %v", got, err)
+	}
+	if got, err := spool.(PendingInspector).PendingStartTimes(ctx); err != nil || len(got) != 1 {
+		t.Errorf("spoolStore.PendingStartTimes() = %v, %v", got, err)
+	}
+}
+
+// storeOnly hides the optional interfaces of Store.
+type storeOnly struct {
+	Store
+}
+
+func TestNewSpoolStore_fallbacks(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemoryStore()
+	_ = mem.SaveTxState(ctx, &TxState{TxId: "tx1", Status: StatusConfirming, Services: []ServiceState{{Name: "s1"}}})
+	spool := NewSpoolStore(storeOnly{mem}, filepath.Join(t.TempDir(), "spool"))
+	if got, err := spool.(Claimer).ClaimTx(ctx, "tx1"); err != nil || got.TxId != "tx1" {
+		t.Errorf("spoolStore.ClaimTx() = %+v, %v, want tx1 loaded by LoadPendingTx", got, err)
+	}
+	if _, err := spool.(Claimer).ClaimTx(ctx, "tx2"); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("spoolStore.ClaimTx() error = %v, want %v", err, ErrTxNotFound)
+	}
+	if _, err := spool.(Getter).GetTx(ctx, "tx1"); err == nil {
+		t.Errorf("spoolStore.GetTx() error = nil, but store implements neither Getter nor Lister")
+	}
+	if _, _, err := spool.(Lister).ListTx(ctx, "", 10); err == nil {
+		t.Errorf("spoolStore.ListTx() error = nil, but store does not implement Lister")
+	}
+}
+
+func TestNewSpoolStore_tornRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "spool")
+	store := &downStore{Store: NewMemoryStore(), down: true}
+	state1 := &TxState{TxId: "tx1", Status: StatusConfirming, Services: []ServiceState{{Name: "s1", TrySucceeded: true}}}
+	if err := NewSpoolStore(store, path).SaveTxState(ctx, state1); err != nil {
+		t.Fatalf("spoolStore.SaveTxState() error = %v", err)
+	}
+	// a crash tears the record appended after tx1
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"state":{"txId":"tx`)
+	_ = f.Close()
+
+	// after restart, the store is still down
+	spool := NewSpoolStore(store, path)
+	state2 := &TxState{TxId: "tx2", Status: StatusCanceling, Services: []ServiceState{{Name: "s1", TrySucceeded: true}}}
+	if err := spool.SaveTxState(ctx, state2); err != nil {
+		t.Fatalf("spoolStore.SaveTxState() error = %v", err)
+	}
+	store.down = false
+	got, err := spool.LoadPendingTx(ctx)
+	if err != nil {
+		t.Fatalf("spoolStore.LoadPendingTx() error = %v", err)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].TxId < got[j].TxId })
+	if want := []*TxState{state1, state2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("spoolStore.LoadPendingTx() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewSpoolStore_corruptRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "spool")
+	journal := "not json\n" + `{"state":{"txId":"tx1","status":2,"services":[{"name":"s1"}]}}` + "\n"
+	if err := os.WriteFile(path, []byte(journal), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSpoolStore(NewMemoryStore(), path).LoadPendingTx(ctx); err == nil {
+		t.Error("spoolStore.LoadPendingTx() error = nil with a corrupt record")
+	}
+	if data, _ := os.ReadFile(path); string(data) != journal {
+		t.Errorf("journal = %q, want it kept", data)
+	}
+}
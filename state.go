@@ -0,0 +1,101 @@
+package tcc
+
+import "fmt"
+
+// ServiceStatus is the progress of a service in a transaction.
+type ServiceStatus int
+
+const (
+	// ServiceNotStarted means try of the service is not called yet.
+	ServiceNotStarted ServiceStatus = iota
+
+	// ServiceTried means try of the service is called, but has not succeeded.
+	ServiceTried
+
+	// ServiceTrySucceeded means try of the service succeeded, and neither confirm nor cancel is called yet.
+	ServiceTrySucceeded
+
+	// ServiceConfirming means confirm of the service is called, but has not succeeded.
+	ServiceConfirming
+
+	// ServiceConfirmed means confirm of the service succeeded.
+	ServiceConfirmed
+
+	// ServiceCanceling means cancel of the service is called, but has not succeeded.
+	ServiceCanceling
+
+	// ServiceCanceled means cancel of the service succeeded.
+	ServiceCanceled
+)
+
+func (s ServiceStatus) String() string {
+	switch s {
+	case ServiceNotStarted:
+		return "not started"
+	case ServiceTried:
+		return "tried"
+	case ServiceTrySucceeded:
+		return "try succeeded"
+	case ServiceConfirming:
+		return "confirming"
+	case ServiceConfirmed:
+		return "confirmed"
+	case ServiceCanceling:
+		return "canceling"
+	case ServiceCanceled:
+		return "canceled"
+	default:
+		return fmt.Sprintf("ServiceStatus(%d)", int(s))
+	}
+}
+
+// State is a snapshot of a transaction and its services returned by State.
+type State struct {
+	TxId     string
+	Status   Status
+	Services []ServiceProgress
+}
+
+// ServiceProgress is the progress of a service in State.
+type ServiceProgress struct {
+	Name   string
+	Status ServiceStatus
+}
+
+// Status returns the progress of the service in the transaction it was last passed to.
+// Use State of the director instead while the transaction is in progress.
+func (s *Service) Status() ServiceStatus {
+	switch {
+	case s.cancelSucceeded:
+		return ServiceCanceled
+	case s.canceled:
+		return ServiceCanceling
+	case s.confirmSucceeded:
+		return ServiceConfirmed
+	case s.confirmed:
+		return ServiceConfirming
+	case s.trySucceeded:
+		return ServiceTrySucceeded
+	case s.tried:
+		return ServiceTried
+	default:
+		return ServiceNotStarted
+	}
+}
+
+func (d *director) State() *State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := &State{TxId: d.txId, Status: d.status}
+	for _, s := range d.services {
+		state.Services = append(state.Services, ServiceProgress{Name: s.name, Status: s.Status()})
+	}
+	return state
+}
+
+// update changes the progress of services under mu, so that State can be called while they are called.
+func (d *director) update(f func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f()
+}
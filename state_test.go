@@ -0,0 +1,82 @@
+package tcc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_director_State(t *testing.T) {
+	ok := func() error { return nil }
+	tests := []struct {
+		name   string
+		tryErr error
+		want   *State
+	}{
+		{
+			name: "confirmed",
+			want: &State{Status: StatusConfirmed, Services: []ServiceProgress{
+				{Name: "s1", Status: ServiceConfirmed},
+				{Name: "s2", Status: ServiceConfirmed},
+			}},
+		},
+		{
+			name:   "canceled",
+			tryErr: errors.New("test"),
+			want: &State{Status: StatusCanceled, Services: []ServiceProgress{
+				{Name: "s1", Status: ServiceCanceled},
+				{Name: "s2", Status: ServiceTried},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDirector([]*Service{
+				NewService("s1", ok, ok, ok),
+				NewService("s2", func() error { return tt.tryErr }, ok, ok),
+			})
+			_ = d.Direct()
+			got := d.State()
+			tt.want.TxId = got.TxId
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("director.State() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_director_State_InProgress(t *testing.T) {
+	trying := make(chan struct{})
+	release := make(chan struct{})
+	tx := NewTransaction([]*Service{
+		NewService("s1", func() error {
+			close(trying)
+			<-release
+			return nil
+		}, func() error { return nil }, func() error { return nil }),
+	})
+	tx.Start()
+	<-trying
+	want := []ServiceProgress{{Name: "s1", Status: ServiceTried}}
+	if got := tx.State(); got.Status != StatusTrying || !reflect.DeepEqual(got.Services, want) {
+		t.Errorf("Transaction.State() = %+v while trying", got)
+	}
+	close(release)
+	if err := tx.Wait(); err != nil {
+		t.Fatalf("Transaction.Wait() error = %v", err)
+	}
+	if got := tx.State().Services[0].Status; got != ServiceConfirmed {
+		t.Errorf("service status = %v, want %v", got, ServiceConfirmed)
+	}
+}
+
+func TestService_accessors(t *testing.T) {
+	s := NewService("s1", func() error { return nil }, func() error { return nil }, func() error { return nil })
+	if err := NewDirector([]*Service{s}).Direct(); err != nil {
+		t.Fatalf("director.Direct() error = %v", err)
+	}
+	got := []bool{s.Tried(), s.TrySucceeded(), s.Confirmed(), s.ConfirmSucceeded(), s.Canceled(), s.CancelSucceeded()}
+	if want := []bool{true, true, true, true, false, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("accessors = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,425 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTxNotFound is returned by Store when the transaction is not saved.
+	ErrTxNotFound = errors.New("transaction is not found")
+
+	// ErrUnknownService is returned by Recover when a saved transaction includes
+	// a service which is not passed to Recover.
+	ErrUnknownService = errors.New("service is not passed to Recover")
+)
+
+// Phase is a phase of a service.
+type Phase string
+
+const (
+	// PhaseValidate is validate phase, which is never saved to Store.
+	PhaseValidate Phase = "validate"
+
+	// PhaseTry is try phase.
+	PhaseTry Phase = "try"
+
+	// PhaseConfirm is confirm phase.
+	PhaseConfirm Phase = "confirm"
+
+	// PhaseCancel is cancel phase.
+	PhaseCancel Phase = "cancel"
+)
+
+// TxState is the state of a transaction saved in Store.
+type TxState struct {
+	TxId      string
+	Status    Status
+	StartedAt time.Time
+	Services  []ServiceState
+}
+
+// ServiceState is the state of a service in TxState.
+type ServiceState struct {
+	Name             string
+	TrySucceeded     bool
+	ConfirmSucceeded bool
+	CancelSucceeded  bool
+
+	// TryResult is the result of try returned by TryFunc.
+	TryResult []byte
+
+	// Isolation is the isolation hint of the service set by WithIsolation.
+	Isolation Isolation
+}
+
+// Store persists states of transactions, so that Recover can finish them after the process restarts.
+// Director saves the transaction when it starts try phase, when it decides to confirm or cancel,
+// and when it finishes. A transaction failed to confirm or cancel stays Confirming or Canceling,
+// so that it is recovered.
+type Store interface {
+	// SaveTxState saves state of the transaction, replacing the saved one.
+	SaveTxState(ctx context.Context, state *TxState) error
+
+	// MarkPhaseDone records that the phase of the service in the transaction succeeded.
+	MarkPhaseDone(ctx context.Context, txId, serviceName string, phase Phase) error
+
+	// LoadPendingTx returns transactions which are Trying, Confirming or Canceling.
+	LoadPendingTx(ctx context.Context) ([]*TxState, error)
+}
+
+// PendingInspector is implemented by Store which can inspect pending transactions without claiming them,
+// unlike LoadPendingTx.
+type PendingInspector interface {
+	// PendingStartTimes returns when every pending transaction started.
+	PendingStartTimes(ctx context.Context) ([]time.Time, error)
+}
+
+// Lister is implemented by Store which can list every saved transaction page by page,
+// e.g. for admin listings and scans over large stores with bounded memory usage.
+type Lister interface {
+	// ListTx returns at most limit transactions whose ID is greater than the cursor after, ordered by ID,
+	// and the cursor of the next page, which is empty on the last page. Pass empty after for the first page.
+	ListTx(ctx context.Context, after string, limit int) ([]*TxState, string, error)
+}
+
+// Getter is implemented by Store which can load a transaction by ID without claiming it,
+// e.g. for admin lookups which must not take leases from coordinators.
+type Getter interface {
+	// GetTx returns the transaction saved in store, pending or finished, or ErrTxNotFound.
+	GetTx(ctx context.Context, txId string) (*TxState, error)
+}
+
+// Claimer is implemented by Store which leases pending transactions to the coordinator which loaded them,
+// and can claim a single one, e.g. to retry it by hand.
+type Claimer interface {
+	// ClaimTx leases the pending transaction to the caller like LoadPendingTx does, even if its lease is not expired,
+	// and returns it. It returns ErrTxNotFound if the transaction is not pending.
+	ClaimTx(ctx context.Context, txId string) (*TxState, error)
+}
+
+// StatusCounter is implemented by Store which can count transactions by status without loading them,
+// e.g. for summary tiles of dashboards.
+type StatusCounter interface {
+	// CountByStatus returns how many transactions which started at or after since are in each status.
+	// Transactions which never started try phase are counted if since is zero.
+	CountByStatus(ctx context.Context, since time.Time) (map[Status]int, error)
+}
+
+// EachTx calls f for every transaction saved in store, loading pageSize transactions at a time,
+// and stops at the first error returned by f. store has to implement Lister.
+func EachTx(ctx context.Context, store Store, pageSize int, f func(state *TxState) error) error {
+	lister, ok := store.(Lister)
+	if !ok {
+		return fmt.Errorf("%T does not implement Lister", store)
+	}
+	if pageSize <= 0 {
+		return fmt.Errorf("page size must be positive: %d", pageSize)
+	}
+	after := ""
+	for {
+		states, next, err := lister.ListTx(ctx, after, pageSize)
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			if err := f(state); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		after = next
+	}
+}
+
+// PendingAges returns ages of the transactions pending in store at now, oldest first,
+// e.g. to export their histogram and the age of the oldest one as a gauge.
+// A transaction stuck in confirm or cancel phase grows old, so they are the signal to alert on.
+// store has to implement PendingInspector.
+func PendingAges(ctx context.Context, store Store, now time.Time) ([]time.Duration, error) {
+	inspector, ok := store.(PendingInspector)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement PendingInspector", store)
+	}
+	startTimes, err := inspector.PendingStartTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ages := make([]time.Duration, len(startTimes))
+	for i, startedAt := range startTimes {
+		ages[i] = now.Sub(startedAt)
+	}
+	sort.Slice(ages, func(i, j int) bool { return ages[i] > ages[j] })
+	return ages, nil
+}
+
+// WithStore sets Store to persist states of transactions.
+func WithStore(store Store) Option {
+	return func(d *director) {
+		d.store = store
+	}
+}
+
+// Recover finishes transactions left pending in store, e.g. by a crash of the process.
+// services are matched to saved services by name, and options are used for the director of each transaction.
+// A transaction which was confirming is confirmed. A transaction which was trying or canceling is canceled,
+// calling cancel of every service which is not canceled yet because its try might have succeeded,
+// so cancel must tolerate being called for a service whose try never succeeded.
+// It tries every pending transaction, and returns the first error.
+func Recover(ctx context.Context, store Store, services []*Service, opts ...Option) error {
+	states, err := store.LoadPendingTx(ctx)
+	if err != nil {
+		return &CoordinatorError{op: "load pending transactions", err: err}
+	}
+	byName := map[string]*Service{}
+	for _, s := range services {
+		byName[s.name] = s
+	}
+	var first error
+	for _, state := range states {
+		if err := recoverTx(ctx, store, state, byName, opts); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// RecoverTx finishes the transaction txId pending in store like Recover, e.g. to retry a stuck transaction manually.
+// If store implements Claimer, only the transaction is claimed, otherwise it is looked up by LoadPendingTx.
+// It returns ErrTxNotFound if the transaction is not pending.
+func RecoverTx(ctx context.Context, store Store, txId string, services []*Service, opts ...Option) error {
+	byName := map[string]*Service{}
+	for _, s := range services {
+		byName[s.name] = s
+	}
+	if claimer, ok := store.(Claimer); ok {
+		state, err := claimer.ClaimTx(ctx, txId)
+		if errors.Is(err, ErrTxNotFound) {
+			return fmt.Errorf("recover transaction %s: %w", txId, err)
+		}
+		if err != nil {
+			return &CoordinatorError{op: "claim transaction " + txId, err: err}
+		}
+		return recoverTx(ctx, store, state, byName, opts)
+	}
+	states, err := store.LoadPendingTx(ctx)
+	if err != nil {
+		return &CoordinatorError{op: "load pending transactions", err: err}
+	}
+	for _, state := range states {
+		if state.TxId == txId {
+			return recoverTx(ctx, store, state, byName, opts)
+		}
+	}
+	return fmt.Errorf("recover transaction %s: %w", txId, ErrTxNotFound)
+}
+
+// RecoverState finishes the pending transaction state like Recover, where state is already claimed from store,
+// e.g. by LoadPendingTx.
+func RecoverState(ctx context.Context, store Store, state *TxState, services []*Service, opts ...Option) error {
+	byName := map[string]*Service{}
+	for _, s := range services {
+		byName[s.name] = s
+	}
+	return recoverTx(ctx, store, state, byName, opts)
+}
+
+func recoverTx(ctx context.Context, store Store, state *TxState, byName map[string]*Service, opts []Option) error {
+	services := make([]*Service, len(state.Services))
+	for i, ss := range state.Services {
+		s, ok := byName[ss.Name]
+		if !ok {
+			return fmt.Errorf("recover transaction %s: %w: %s", state.TxId, ErrUnknownService, ss.Name)
+		}
+		c := *s
+		services[i] = &c
+	}
+	d := newDirector(services, append(opts, WithStore(store))...)
+	d.txId = state.TxId
+	d.startedAt = state.StartedAt
+	for i, ss := range state.Services {
+		s := services[i]
+		s.txId = state.TxId
+		// try might have succeeded before the crash even if it is not marked
+		s.tried = true
+		s.trySucceeded = true
+		s.confirmSucceeded = ss.ConfirmSucceeded
+		s.cancelSucceeded = ss.CancelSucceeded
+		s.tryResult = ss.TryResult
+		if ss.Isolation != IsolationDefault {
+			s.isolation = ss.Isolation
+		}
+	}
+	if err := d.recovery.wait(ctx, redriven(state)); err != nil {
+		return fmt.Errorf("recover transaction %s: %w", state.TxId, err)
+	}
+	d.status = StatusFailed
+	d.confirmStarted = state.Status == StatusConfirming
+	return d.resume(ctx)
+}
+
+// txState returns state of the transaction to save.
+func (d *director) txState(status Status) *TxState {
+	state := &TxState{TxId: d.txId, Status: status, StartedAt: d.startedAt}
+	for _, s := range d.services {
+		state.Services = append(state.Services, ServiceState{
+			Name:             s.name,
+			TrySucceeded:     s.trySucceeded,
+			ConfirmSucceeded: s.confirmSucceeded,
+			CancelSucceeded:  s.cancelSucceeded,
+			TryResult:        s.tryResult,
+			Isolation:        s.isolation,
+		})
+	}
+	return state
+}
+
+func (d *director) save(ctx context.Context, status Status) error {
+	if d.store == nil {
+		return nil
+	}
+	return d.store.SaveTxState(ctx, d.txState(status))
+}
+
+// markDone records the phase of the service succeeded.
+// The error is ignored because the phase is just done again on recovery.
+func (d *director) markDone(ctx context.Context, s *Service, phase Phase) {
+	if d.store == nil {
+		return
+	}
+	_ = d.store.MarkPhaseDone(ctx, d.txId, s.name, phase)
+}
+
+type memoryStore struct {
+	txs map[string]*TxState
+
+	sync.Mutex
+}
+
+// NewMemoryStore returns Store which keeps states in memory.
+// States are lost when the process exits, so it is useful for tests and for Resume-like recovery in the process.
+func NewMemoryStore() Store {
+	return &memoryStore{txs: map[string]*TxState{}}
+}
+
+func (m *memoryStore) SaveTxState(ctx context.Context, state *TxState) error {
+	m.Lock()
+	defer m.Unlock()
+	m.txs[state.TxId] = copyTxState(state)
+	return nil
+}
+
+func (m *memoryStore) MarkPhaseDone(ctx context.Context, txId, serviceName string, phase Phase) error {
+	m.Lock()
+	defer m.Unlock()
+	state, ok := m.txs[txId]
+	if !ok {
+		return ErrTxNotFound
+	}
+	for i := range state.Services {
+		if state.Services[i].Name == serviceName {
+			markPhaseDone(&state.Services[i], phase)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: service %s", ErrTxNotFound, serviceName)
+}
+
+func (m *memoryStore) LoadPendingTx(ctx context.Context) ([]*TxState, error) {
+	m.Lock()
+	defer m.Unlock()
+	var states []*TxState
+	for _, state := range m.txs {
+		if state.Status.pending() {
+			states = append(states, copyTxState(state))
+		}
+	}
+	return states, nil
+}
+
+func (m *memoryStore) GetTx(ctx context.Context, txId string) (*TxState, error) {
+	m.Lock()
+	defer m.Unlock()
+	state, ok := m.txs[txId]
+	if !ok {
+		return nil, ErrTxNotFound
+	}
+	return copyTxState(state), nil
+}
+
+func (m *memoryStore) ClaimTx(ctx context.Context, txId string) (*TxState, error) {
+	state, err := m.GetTx(ctx, txId)
+	if err == nil && !state.Status.pending() {
+		return nil, ErrTxNotFound
+	}
+	return state, err
+}
+
+func (m *memoryStore) PendingStartTimes(ctx context.Context) ([]time.Time, error) {
+	m.Lock()
+	defer m.Unlock()
+	var startTimes []time.Time
+	for _, state := range m.txs {
+		if state.Status.pending() {
+			startTimes = append(startTimes, state.StartedAt)
+		}
+	}
+	return startTimes, nil
+}
+
+func (m *memoryStore) ListTx(ctx context.Context, after string, limit int) ([]*TxState, string, error) {
+	m.Lock()
+	defer m.Unlock()
+	var ids []string
+	for id := range m.txs {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	next := ""
+	if len(ids) > limit {
+		ids = ids[:limit]
+		next = ids[limit-1]
+	}
+	states := make([]*TxState, len(ids))
+	for i, id := range ids {
+		states[i] = copyTxState(m.txs[id])
+	}
+	return states, next, nil
+}
+
+func (m *memoryStore) CountByStatus(ctx context.Context, since time.Time) (map[Status]int, error) {
+	m.Lock()
+	defer m.Unlock()
+	counts := map[Status]int{}
+	for _, state := range m.txs {
+		if !state.StartedAt.Before(since) {
+			counts[state.Status]++
+		}
+	}
+	return counts, nil
+}
+
+func markPhaseDone(s *ServiceState, phase Phase) {
+	switch phase {
+	case PhaseTry:
+		s.TrySucceeded = true
+	case PhaseConfirm:
+		s.ConfirmSucceeded = true
+	case PhaseCancel:
+		s.CancelSucceeded = true
+	}
+}
+
+func copyTxState(state *TxState) *TxState {
+	c := *state
+	c.Services = append([]ServiceState(nil), state.Services...)
+	return &c
+}
@@ -0,0 +1,434 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_memoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, state := range []*TxState{
+		{TxId: "tx1", Status: StatusTrying, Services: []ServiceState{{Name: "s1"}, {Name: "s2"}}},
+		{TxId: "tx2", Status: StatusConfirmed, Services: []ServiceState{{Name: "s1"}}},
+	} {
+		if err := store.SaveTxState(ctx, state); err != nil {
+			t.Fatalf("memoryStore.SaveTxState() error = %v", err)
+		}
+	}
+	if err := store.MarkPhaseDone(ctx, "tx1", "s2", PhaseTry); err != nil {
+		t.Errorf("memoryStore.MarkPhaseDone() error = %v", err)
+	}
+	if err := store.MarkPhaseDone(ctx, "tx3", "s1", PhaseTry); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("memoryStore.MarkPhaseDone() error = %v, want %v", err, ErrTxNotFound)
+	}
+	got, err := store.LoadPendingTx(ctx)
+	if err != nil {
+		t.Fatalf("memoryStore.LoadPendingTx() error = %v", err)
+	}
+	want := []*TxState{
+		{TxId: "tx1", Status: StatusTrying, Services: []ServiceState{{Name: "s1"}, {Name: "s2", TrySucceeded: true}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("memoryStore.LoadPendingTx() = %+v, want %+v", got, want)
+	}
+	if state, err := store.(Getter).GetTx(ctx, "tx2"); err != nil || state.Status != StatusConfirmed {
+		t.Errorf("memoryStore.GetTx() = %+v, %v", state, err)
+	}
+	if state, err := store.(Claimer).ClaimTx(ctx, "tx1"); err != nil || !reflect.DeepEqual(state, want[0]) {
+		t.Errorf("memoryStore.ClaimTx() = %+v, %v, want %+v", state, err, want[0])
+	}
+	if _, err := store.(Claimer).ClaimTx(ctx, "tx2"); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("memoryStore.ClaimTx() of finished transaction error = %v, want %v", err, ErrTxNotFound)
+	}
+}
+
+// failingStore fails to save the status.
+type failingStore struct {
+	Store
+	status Status
+}
+
+func (s *failingStore) SaveTxState(ctx context.Context, state *TxState) error {
+	if state.Status == s.status {
+		return errors.New("store is down")
+	}
+	return s.Store.SaveTxState(ctx, state)
+}
+
+func Test_director_Direct_Store(t *testing.T) {
+	tests := []struct {
+		name        string
+		tryErr      error
+		confirmErr  error
+		failStatus  Status
+		wantTried   bool
+		wantPending []Status
+	}{
+		{
+			name:      "confirmed",
+			wantTried: true,
+		},
+		{
+			name:      "canceled",
+			tryErr:    errors.New("test"),
+			wantTried: true,
+		},
+		{
+			name:        "confirm failed",
+			confirmErr:  errors.New("test"),
+			wantTried:   true,
+			wantPending: []Status{StatusConfirming},
+		},
+		{
+			name:       "saving before try failed",
+			failStatus: StatusTrying,
+			wantTried:  false,
+		},
+		{
+			name:       "saving decision to confirm failed",
+			failStatus: StatusConfirming,
+			wantTried:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tried, confirmed bool
+			store := &failingStore{Store: NewMemoryStore(), status: tt.failStatus}
+			if tt.failStatus == StatusNotStarted {
+				store.status = -1
+			}
+			d := NewDirector([]*Service{
+				NewService(
+					"s1",
+					func() error { tried = true; return tt.tryErr },
+					func() error { confirmed = true; return tt.confirmErr },
+					func() error { return nil },
+				),
+			}, WithStore(store), WithMaxRetries(0))
+			err := d.Direct()
+			wantErr := tt.tryErr != nil || tt.confirmErr != nil || tt.failStatus != StatusNotStarted
+			if (err != nil) != wantErr {
+				t.Errorf("director.Direct() error = %v, wantErr %v", err, wantErr)
+			}
+			if tried != tt.wantTried {
+				t.Errorf("tried = %v, want %v", tried, tt.wantTried)
+			}
+			if tt.failStatus == StatusConfirming && confirmed {
+				t.Errorf("confirmed although the decision is not saved")
+			}
+			pending, _ := store.LoadPendingTx(context.Background())
+			var got []Status
+			for _, state := range pending {
+				got = append(got, state.Status)
+			}
+			if !reflect.DeepEqual(got, tt.wantPending) {
+				t.Errorf("pending transactions = %v, want %v", got, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name          string
+		state         *TxState
+		wantConfirmed []string
+		wantCanceled  []string
+		wantErr       error
+	}{
+		{
+			name: "confirming",
+			state: &TxState{TxId: "tx1", Status: StatusConfirming, Services: []ServiceState{
+				{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true},
+				{Name: "s2", TrySucceeded: true},
+			}},
+			wantConfirmed: []string{"s2"},
+		},
+		{
+			name: "trying",
+			state: &TxState{TxId: "tx1", Status: StatusTrying, Services: []ServiceState{
+				{Name: "s1", TrySucceeded: true},
+				{Name: "s2"},
+			}},
+			wantCanceled: []string{"s1", "s2"},
+		},
+		{
+			name: "canceling",
+			state: &TxState{TxId: "tx1", Status: StatusCanceling, Services: []ServiceState{
+				{Name: "s1", TrySucceeded: true, CancelSucceeded: true},
+				{Name: "s2", TrySucceeded: true},
+			}},
+			wantCanceled: []string{"s2"},
+		},
+		{
+			name: "unknown service",
+			state: &TxState{TxId: "tx1", Status: StatusConfirming, Services: []ServiceState{
+				{Name: "s3", TrySucceeded: true},
+			}},
+			wantErr: ErrUnknownService,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			if err := store.SaveTxState(ctx, tt.state); err != nil {
+				t.Fatal(err)
+			}
+			var confirmed, canceled []string
+			var services []*Service
+			for _, name := range []string{"s1", "s2"} {
+				name := name
+				services = append(services, NewService(
+					name,
+					func() error { t.Errorf("try is called on recovery"); return nil },
+					func() error { confirmed = append(confirmed, name); return nil },
+					func() error { canceled = append(canceled, name); return nil },
+				))
+			}
+			err := Recover(ctx, store, services, WithDeterministicSchedule(1))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Recover() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !reflect.DeepEqual(confirmed, tt.wantConfirmed) {
+				t.Errorf("confirmed = %v, want %v", confirmed, tt.wantConfirmed)
+			}
+			if len(canceled) != len(tt.wantCanceled) {
+				t.Errorf("canceled = %v, want %v", canceled, tt.wantCanceled)
+			}
+			pending, _ := store.LoadPendingTx(ctx)
+			if len(pending) != 0 {
+				t.Errorf("pending transactions after Recover() = %+v", pending)
+			}
+		})
+	}
+}
+
+func TestRecover_TryResult(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	err := store.SaveTxState(ctx, &TxState{TxId: "tx1", Status: StatusConfirming, Services: []ServiceState{
+		{Name: "s1", TrySucceeded: true, TryResult: []byte("r1")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	services := []*Service{NewServiceWithResult(
+		"s1",
+		func(context.Context) ([]byte, error) { return nil, nil },
+		func(ctx context.Context, tryResult []byte) error { got = tryResult; return nil },
+		func(ctx context.Context, tryResult []byte) error { return nil },
+	)}
+	if err := Recover(ctx, store, services); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if string(got) != "r1" {
+		t.Errorf("try result passed to confirm = %q, want %q", got, "r1")
+	}
+}
+
+func TestRecover_savedTryResult(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	confirmFails := true
+	var got []byte
+	services := []*Service{NewServiceWithResult(
+		"s1",
+		func(context.Context) ([]byte, error) { return []byte("r1"), nil },
+		func(ctx context.Context, tryResult []byte) error {
+			if confirmFails {
+				return errors.New("confirm")
+			}
+			got = tryResult
+			return nil
+		},
+		func(ctx context.Context, tryResult []byte) error { return nil },
+	)}
+	d := NewDirector(services, WithStore(store), WithMaxRetries(0))
+	if err := d.Direct(); err == nil {
+		t.Fatal("Direct() succeeded")
+	}
+	confirmFails = false
+	if err := Recover(ctx, store, services); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if string(got) != "r1" {
+		t.Errorf("try result passed to confirm = %q, want %q", got, "r1")
+	}
+}
+
+func TestRecover_Isolation(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	var got []Isolation
+	services := []*Service{NewServiceContext(
+		"s1",
+		func(context.Context) error { return nil },
+		func(ctx context.Context) error {
+			info, _ := CallInfoFrom(ctx)
+			got = append(got, info.Isolation)
+			return errors.New("confirm")
+		},
+		func(context.Context) error { return nil },
+		WithIsolation(IsolationLock),
+	)}
+	d := NewDirector(services, WithStore(store), WithMaxRetries(0))
+	if err := d.Direct(); err == nil {
+		t.Fatal("Direct() succeeded")
+	}
+	state, err := store.(Getter).GetTx(ctx, d.TxId())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Services[0].Isolation != IsolationLock {
+		t.Errorf("saved isolation = %q, want %q", state.Services[0].Isolation, IsolationLock)
+	}
+
+	// the service registered for recovery does not know the hint, which is restored from the saved state
+	services[0] = NewServiceContext(
+		"s1",
+		func(context.Context) error { return nil },
+		func(ctx context.Context) error {
+			info, _ := CallInfoFrom(ctx)
+			got = append(got, info.Isolation)
+			return nil
+		},
+		func(context.Context) error { return nil },
+	)
+	if err := Recover(ctx, store, services); err != nil {
+		t.Fatalf("Recover() error = %v", err)
+	}
+	if want := []Isolation{IsolationLock, IsolationLock}; !reflect.DeepEqual(got, want) {
+		t.Errorf("isolation passed to confirm = %v, want %v", got, want)
+	}
+}
+
+func TestPendingAges(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	store := NewMemoryStore()
+	for _, state := range []*TxState{
+		{TxId: "tx1", Status: StatusConfirming, StartedAt: now.Add(-time.Minute)},
+		{TxId: "tx2", Status: StatusCanceling, StartedAt: now.Add(-time.Hour)},
+		{TxId: "tx3", Status: StatusConfirmed, StartedAt: now.Add(-2 * time.Hour)},
+	} {
+		if err := store.SaveTxState(ctx, state); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := PendingAges(ctx, store, now)
+	if err != nil {
+		t.Fatalf("PendingAges() error = %v", err)
+	}
+	if want := []time.Duration{time.Hour, time.Minute}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PendingAges() = %v, want %v", got, want)
+	}
+	if _, err := PendingAges(ctx, &failingStore{Store: store}, now); err == nil {
+		t.Errorf("PendingAges() of Store without PendingInspector error = nil, wantErr")
+	}
+}
+
+func Test_director_Direct_StartedAt(t *testing.T) {
+	store := NewMemoryStore()
+	before := time.Now()
+	d := NewDirector([]*Service{
+		NewService("s1", func() error { return nil }, func() error { return errors.New("test") }, func() error { return nil }),
+	}, WithStore(store), WithMaxRetries(0))
+	_ = d.Direct()
+	pending, _ := store.LoadPendingTx(context.Background())
+	if len(pending) != 1 || pending[0].StartedAt.Before(before) {
+		t.Errorf("pending transactions = %+v, want one started after %v", pending, before)
+	}
+}
+
+func TestEachTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, txId := range []string{"tx3", "tx1", "tx5", "tx2", "tx4"} {
+		_ = store.SaveTxState(ctx, &TxState{TxId: txId, Status: StatusConfirmed})
+	}
+	var got []string
+	err := EachTx(ctx, store, 2, func(state *TxState) error {
+		got = append(got, state.TxId)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachTx() error = %v", err)
+	}
+	if want := []string{"tx1", "tx2", "tx3", "tx4", "tx5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EachTx() visited %v, want %v", got, want)
+	}
+
+	stop := errors.New("stop")
+	if err := EachTx(ctx, store, 2, func(*TxState) error { return stop }); err != stop {
+		t.Errorf("EachTx() error = %v, want %v", err, stop)
+	}
+	if err := EachTx(ctx, &failingStore{}, 2, func(*TxState) error { return nil }); err == nil {
+		t.Error("EachTx() error = nil for Store which does not implement Lister")
+	}
+}
+
+func Test_memoryStore_CountByStatus(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	_ = store.SaveTxState(ctx, &TxState{TxId: "tx1", Status: StatusConfirmed, StartedAt: time.Unix(100, 0)})
+	_ = store.SaveTxState(ctx, &TxState{TxId: "tx2", Status: StatusConfirmed, StartedAt: time.Unix(200, 0)})
+	_ = store.SaveTxState(ctx, &TxState{TxId: "tx3", Status: StatusFailed, StartedAt: time.Unix(300, 0)})
+	got, err := store.(StatusCounter).CountByStatus(ctx, time.Unix(200, 0))
+	if err != nil {
+		t.Fatalf("memoryStore.CountByStatus() error = %v", err)
+	}
+	if want := map[Status]int{StatusConfirmed: 1, StatusFailed: 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("memoryStore.CountByStatus() = %v, want %v", got, want)
+	}
+}
+
+func TestRecoverTx(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for _, id := range []string{"tx1", "tx2"} {
+		err := store.SaveTxState(ctx, &TxState{TxId: id, Status: StatusConfirming, Services: []ServiceState{
+			{Name: "s1", TrySucceeded: true},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var confirmed int
+	services := []*Service{NewService("s1", func() error { return nil }, func() error { confirmed++; return nil }, func() error { return nil })}
+	if err := RecoverTx(ctx, store, "tx2", services); err != nil {
+		t.Fatalf("RecoverTx() error = %v", err)
+	}
+	states, _ := store.LoadPendingTx(ctx)
+	if confirmed != 1 || len(states) != 1 || states[0].TxId != "tx1" {
+		t.Errorf("confirmed %d times, pending %+v, want tx2 confirmed once", confirmed, states)
+	}
+	if err := RecoverTx(ctx, store, "tx2", services); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("RecoverTx() of finished transaction error = %v, want %v", err, ErrTxNotFound)
+	}
+}
+
+func TestRecoverState(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	state := &TxState{TxId: "tx1", Status: StatusCanceling, Services: []ServiceState{{Name: "s1", TrySucceeded: true}}}
+	if err := store.SaveTxState(ctx, state); err != nil {
+		t.Fatal(err)
+	}
+	var canceled int
+	services := []*Service{NewService("s1", func() error { return nil }, func() error { return nil }, func() error { canceled++; return nil })}
+	if err := RecoverState(ctx, store, state, services); err != nil {
+		t.Fatalf("RecoverState() error = %v", err)
+	}
+	if states, _ := store.LoadPendingTx(ctx); canceled != 1 || len(states) != 0 {
+		t.Errorf("canceled %d times, pending %+v, want tx1 canceled once", canceled, states)
+	}
+}
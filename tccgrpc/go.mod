@@ -0,0 +1,20 @@
+module github.com/dllen/g-tcc/tccgrpc
+
+go 1.19
+
+require (
+	github.com/dllen/g-tcc v0.0.0
+	google.golang.org/grpc v1.64.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	github.com/cenkalti/backoff/v3 v3.1.1 // indirect
+	github.com/rs/xid v1.2.1 // indirect
+	golang.org/x/net v0.22.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+)
+
+replace github.com/dllen/g-tcc => ../
@@ -0,0 +1,81 @@
+package tccgrpc
+
+import (
+	"context"
+
+	"github.com/dllen/g-tcc"
+	"github.com/dllen/g-tcc/tccgrpc/tccpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Participant implements the phases of a participant served by NewServer.
+// Each of them may be called more than once for the same transaction, so it must be idempotent by req.TxId.
+// Errors annotated by tcc.WithCode are returned with the status code of the code, see Status.
+type Participant interface {
+	// Try reserves resources, and returns the result passed to Confirm and Cancel, e.g. a reservation ID.
+	Try(ctx context.Context, req *tccpb.PhaseRequest) ([]byte, error)
+
+	// Confirm commits the reservation.
+	Confirm(ctx context.Context, req *tccpb.PhaseRequest) error
+
+	// Cancel releases the reservation. It may be called even if Try never succeeded,
+	// and can return tcc.WithCode(err, tcc.CodeNotFound) if there is nothing to release.
+	Cancel(ctx context.Context, req *tccpb.PhaseRequest) error
+}
+
+// NewServer returns tccpb.ParticipantServer calling p, to be registered by tccpb.RegisterParticipantServer.
+func NewServer(p Participant) tccpb.ParticipantServer {
+	return &server{p: p}
+}
+
+type server struct {
+	tccpb.UnimplementedParticipantServer
+	p Participant
+}
+
+func (s *server) Try(ctx context.Context, req *tccpb.PhaseRequest) (*tccpb.PhaseResponse, error) {
+	result, err := s.p.Try(ctx, req)
+	if err != nil {
+		return nil, Status(err).Err()
+	}
+	return &tccpb.PhaseResponse{Result: result}, nil
+}
+
+func (s *server) Confirm(ctx context.Context, req *tccpb.PhaseRequest) (*tccpb.PhaseResponse, error) {
+	if err := s.p.Confirm(ctx, req); err != nil {
+		return nil, Status(err).Err()
+	}
+	return &tccpb.PhaseResponse{}, nil
+}
+
+func (s *server) Cancel(ctx context.Context, req *tccpb.PhaseRequest) (*tccpb.PhaseResponse, error) {
+	if err := s.p.Cancel(ctx, req); err != nil {
+		return nil, Status(err).Err()
+	}
+	return &tccpb.PhaseResponse{}, nil
+}
+
+// Status returns gRPC status of err returned by a participant, which StatusCode maps back to tcc.CodeOf(err).
+// An error which already is a gRPC status is returned as is.
+func Status(err error) *status.Status {
+	if s, ok := status.FromError(err); ok {
+		return s
+	}
+	var code codes.Code
+	switch tcc.CodeOf(err) {
+	case tcc.CodeNotFound:
+		code = codes.NotFound
+	case tcc.CodeConflict:
+		code = codes.Aborted
+	case tcc.CodeThrottled:
+		code = codes.ResourceExhausted
+	case tcc.CodePermanent:
+		code = codes.InvalidArgument
+	case tcc.CodeRetryable:
+		code = codes.Unavailable
+	default:
+		code = codes.Unknown
+	}
+	return status.New(code, err.Error())
+}
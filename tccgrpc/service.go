@@ -0,0 +1,125 @@
+// Package tccgrpc provides tcc.Service calling a participant over gRPC, and a server helper implementing one.
+// The participant is defined by tccpb/participant.proto.
+//
+// It is a separate module, so that the core module does not depend on grpc.
+package tccgrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dllen/g-tcc"
+	"github.com/dllen/g-tcc/tccgrpc/tccpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Option can set option to the service.
+type Option func(c *caller)
+
+// WithPayload sets function returning payload of the request of the phase.
+// tryResult is nil in try phase. By default try sends no payload,
+// and confirm and cancel send the result of try.
+func WithPayload(payload func(phase tcc.Phase, tryResult []byte) ([]byte, error)) Option {
+	return func(c *caller) {
+		c.payload = payload
+	}
+}
+
+// WithCallOptions sets grpc.CallOption of every call, e.g. grpc.WaitForReady.
+func WithCallOptions(opts ...grpc.CallOption) Option {
+	return func(c *caller) {
+		c.callOpts = append(c.callOpts, opts...)
+	}
+}
+
+// WithServiceOptions sets tcc.ServiceOption of the service, e.g. tcc.WithConfirmTimeout.
+func WithServiceOptions(opts ...tcc.ServiceOption) Option {
+	return func(c *caller) {
+		c.serviceOpts = append(c.serviceOpts, opts...)
+	}
+}
+
+type caller struct {
+	client      tccpb.ParticipantClient
+	payload     func(phase tcc.Phase, tryResult []byte) ([]byte, error)
+	callOpts    []grpc.CallOption
+	serviceOpts []tcc.ServiceOption
+}
+
+// NewService returns tcc.Service which calls Try, Confirm and Cancel of the participant on conn in each phase.
+// The result of Try is passed to Confirm and Cancel. Every request carries the transaction ID and the isolation hint.
+// Error statuses are annotated with StatusCode, so that e.g. NOT_FOUND of cancel is treated as canceled
+// and INVALID_ARGUMENT is not retried.
+func NewService(name string, conn grpc.ClientConnInterface, opts ...Option) *tcc.Service {
+	c := &caller{
+		client: tccpb.NewParticipantClient(conn),
+		payload: func(phase tcc.Phase, tryResult []byte) ([]byte, error) {
+			return tryResult, nil
+		},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return tcc.NewServiceWithResult(
+		name,
+		func(ctx context.Context) ([]byte, error) {
+			return c.call(ctx, tcc.PhaseTry, nil)
+		},
+		func(ctx context.Context, tryResult []byte) error {
+			_, err := c.call(ctx, tcc.PhaseConfirm, tryResult)
+			return err
+		},
+		func(ctx context.Context, tryResult []byte) error {
+			_, err := c.call(ctx, tcc.PhaseCancel, tryResult)
+			return err
+		},
+		c.serviceOpts...,
+	)
+}
+
+// call calls the RPC of the phase, and returns the result.
+func (c *caller) call(ctx context.Context, phase tcc.Phase, tryResult []byte) ([]byte, error) {
+	info, _ := tcc.CallInfoFrom(ctx)
+	payload, err := c.payload(phase, tryResult)
+	if err != nil {
+		return nil, tcc.Permanent(fmt.Errorf("make %s payload: %w", phase, err))
+	}
+	req := &tccpb.PhaseRequest{TxId: info.TxId, Payload: payload, Isolation: string(info.Isolation)}
+	var resp *tccpb.PhaseResponse
+	switch phase {
+	case tcc.PhaseTry:
+		resp, err = c.client.Try(ctx, req, c.callOpts...)
+	case tcc.PhaseConfirm:
+		resp, err = c.client.Confirm(ctx, req, c.callOpts...)
+	default:
+		resp, err = c.client.Cancel(ctx, req, c.callOpts...)
+	}
+	if err != nil {
+		if code := StatusCode(status.Code(err)); code != tcc.CodeUnknown {
+			return nil, tcc.WithCode(fmt.Errorf("%s: %w", phase, err), code)
+		}
+		return nil, fmt.Errorf("%s: %w", phase, err)
+	}
+	return resp.GetResult(), nil
+}
+
+// StatusCode maps gRPC status code returned by a participant to tcc.Code.
+// OK is mapped to CodeUnknown.
+func StatusCode(code codes.Code) tcc.Code {
+	switch code {
+	case codes.OK:
+		return tcc.CodeUnknown
+	case codes.NotFound:
+		return tcc.CodeNotFound
+	case codes.AlreadyExists, codes.Aborted, codes.FailedPrecondition:
+		return tcc.CodeConflict
+	case codes.ResourceExhausted:
+		return tcc.CodeThrottled
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown, codes.Canceled:
+		return tcc.CodeRetryable
+	default:
+		return tcc.CodePermanent
+	}
+}
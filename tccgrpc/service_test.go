@@ -0,0 +1,199 @@
+package tccgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/dllen/g-tcc"
+	"github.com/dllen/g-tcc/tccgrpc/tccpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type request struct {
+	phase     tcc.Phase
+	txId      string
+	payload   string
+	isolation string
+}
+
+// participant records requests, and fails try with tryErr.
+type participant struct {
+	tryErr error
+
+	mu       sync.Mutex
+	requests []request
+}
+
+func (p *participant) record(phase tcc.Phase, req *tccpb.PhaseRequest) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, request{phase, req.TxId, string(req.Payload), req.Isolation})
+}
+
+func (p *participant) Try(ctx context.Context, req *tccpb.PhaseRequest) ([]byte, error) {
+	p.record(tcc.PhaseTry, req)
+	if p.tryErr != nil {
+		return nil, p.tryErr
+	}
+	return []byte("reservation-1"), nil
+}
+
+func (p *participant) Confirm(ctx context.Context, req *tccpb.PhaseRequest) error {
+	p.record(tcc.PhaseConfirm, req)
+	return nil
+}
+
+func (p *participant) Cancel(ctx context.Context, req *tccpb.PhaseRequest) error {
+	p.record(tcc.PhaseCancel, req)
+	return nil
+}
+
+// dial serves p on an in-memory listener, and returns a connection to it.
+func dial(t *testing.T, p Participant) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	tccpb.RegisterParticipantServer(s, NewServer(p))
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("cannot dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestNewService(t *testing.T) {
+	tests := []struct {
+		name       string
+		p          *participant
+		wantErr    bool
+		wantStatus tcc.Status
+		want       []request
+	}{
+		{
+			name:       "confirmed",
+			p:          &participant{},
+			wantStatus: tcc.StatusConfirmed,
+			want: []request{
+				{tcc.PhaseTry, "tx1", "", "lock"},
+				{tcc.PhaseConfirm, "tx1", "reservation-1", "lock"},
+			},
+		},
+		{
+			name:       "canceled",
+			p:          &participant{tryErr: tcc.WithCode(errors.New("sold out"), tcc.CodeConflict)},
+			wantErr:    true,
+			wantStatus: tcc.StatusCanceled,
+			want: []request{
+				{tcc.PhaseTry, "tx1", "", "lock"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewService("s1", dial(t, tt.p), WithServiceOptions(tcc.WithIsolation(tcc.IsolationLock)))
+			tx := tcc.NewTransaction([]*tcc.Service{s}, tcc.WithTxId("tx1"), tcc.WithMaxRetries(0))
+			if err := tx.Wait(); (err != nil) != tt.wantErr {
+				t.Fatalf("Transaction.Wait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tx.Status(); got != tt.wantStatus {
+				t.Errorf("Transaction.Status() = %v, want %v", got, tt.wantStatus)
+			}
+			if !reflect.DeepEqual(tt.p.requests, tt.want) {
+				t.Errorf("requests = %+v, want %+v", tt.p.requests, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithPayload(t *testing.T) {
+	p := &participant{}
+	s := NewService("s1", dial(t, p), WithPayload(func(phase tcc.Phase, tryResult []byte) ([]byte, error) {
+		return []byte(string(phase) + ":" + string(tryResult)), nil
+	}))
+	if err := tcc.NewTransaction([]*tcc.Service{s}, tcc.WithTxId("tx1")).Wait(); err != nil {
+		t.Fatalf("Transaction.Wait() error = %v", err)
+	}
+	want := []request{
+		{tcc.PhaseTry, "tx1", "try:", ""},
+		{tcc.PhaseConfirm, "tx1", "confirm:reservation-1", ""},
+	}
+	if !reflect.DeepEqual(p.requests, want) {
+		t.Errorf("requests = %+v, want %+v", p.requests, want)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+		// wantCode is the code StatusCode maps the status back to
+		wantCode tcc.Code
+	}{
+		{
+			name:     "not found",
+			err:      tcc.WithCode(errors.New("test"), tcc.CodeNotFound),
+			want:     codes.NotFound,
+			wantCode: tcc.CodeNotFound,
+		},
+		{
+			name:     "conflict",
+			err:      tcc.WithCode(errors.New("test"), tcc.CodeConflict),
+			want:     codes.Aborted,
+			wantCode: tcc.CodeConflict,
+		},
+		{
+			name:     "throttled",
+			err:      tcc.WithCode(errors.New("test"), tcc.CodeThrottled),
+			want:     codes.ResourceExhausted,
+			wantCode: tcc.CodeThrottled,
+		},
+		{
+			name:     "permanent",
+			err:      tcc.Permanent(errors.New("test")),
+			want:     codes.InvalidArgument,
+			wantCode: tcc.CodePermanent,
+		},
+		{
+			name:     "retryable",
+			err:      tcc.WithCode(errors.New("test"), tcc.CodeRetryable),
+			want:     codes.Unavailable,
+			wantCode: tcc.CodeRetryable,
+		},
+		{
+			name:     "unknown",
+			err:      errors.New("test"),
+			want:     codes.Unknown,
+			wantCode: tcc.CodeRetryable,
+		},
+		{
+			name:     "status",
+			err:      status.Error(codes.PermissionDenied, "test"),
+			want:     codes.PermissionDenied,
+			wantCode: tcc.CodePermanent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Status(tt.err).Code()
+			if got != tt.want {
+				t.Errorf("Status().Code() = %v, want %v", got, tt.want)
+			}
+			if code := StatusCode(got); code != tt.wantCode {
+				t.Errorf("StatusCode(%v) = %v, want %v", got, code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package tccpb contains the gRPC definition of a TCC participant generated from participant.proto.
+package tccpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative participant.proto
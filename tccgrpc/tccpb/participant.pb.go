@@ -0,0 +1,248 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: participant.proto
+
+package tccpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PhaseRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	TxId      string `protobuf:"bytes,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	Payload   []byte `protobuf:"bytes,2,opt,name=payload,proto3" json:"payload,omitempty"`
+	Isolation string `protobuf:"bytes,3,opt,name=isolation,proto3" json:"isolation,omitempty"`
+}
+
+func (x *PhaseRequest) Reset() {
+	*x = PhaseRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_participant_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PhaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PhaseRequest) ProtoMessage() {}
+
+func (x *PhaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_participant_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PhaseRequest.ProtoReflect.Descriptor instead.
+func (*PhaseRequest) Descriptor() ([]byte, []int) {
+	return file_participant_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PhaseRequest) GetTxId() string {
+	if x != nil {
+		return x.TxId
+	}
+	return ""
+}
+
+func (x *PhaseRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *PhaseRequest) GetIsolation() string {
+	if x != nil {
+		return x.Isolation
+	}
+	return ""
+}
+
+type PhaseResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Result []byte `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *PhaseResponse) Reset() {
+	*x = PhaseResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_participant_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PhaseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PhaseResponse) ProtoMessage() {}
+
+func (x *PhaseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_participant_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PhaseResponse.ProtoReflect.Descriptor instead.
+func (*PhaseResponse) Descriptor() ([]byte, []int) {
+	return file_participant_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PhaseResponse) GetResult() []byte {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+var File_participant_proto protoreflect.FileDescriptor
+
+var file_participant_proto_rawDesc = []byte{
+	0x0a, 0x11, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x12, 0x12, 0x74, 0x63, 0x63, 0x2e, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69,
+	0x70, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x22, 0x5b, 0x0a, 0x0c, 0x50, 0x68, 0x61, 0x73, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x13, 0x0a, 0x05, 0x74, 0x78, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x78, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07,
+	0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x70,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x69, 0x73, 0x6f, 0x6c, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x69, 0x73, 0x6f, 0x6c, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x22, 0x27, 0x0a, 0x0d, 0x50, 0x68, 0x61, 0x73, 0x65, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x32, 0xf8, 0x01,
+	0x0a, 0x0b, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x12, 0x4a, 0x0a,
+	0x03, 0x54, 0x72, 0x79, 0x12, 0x20, 0x2e, 0x74, 0x63, 0x63, 0x2e, 0x70, 0x61, 0x72, 0x74, 0x69,
+	0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x68, 0x61, 0x73, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x74, 0x63, 0x63, 0x2e, 0x70, 0x61, 0x72,
+	0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x68, 0x61, 0x73,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4e, 0x0a, 0x07, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x72, 0x6d, 0x12, 0x20, 0x2e, 0x74, 0x63, 0x63, 0x2e, 0x70, 0x61, 0x72, 0x74, 0x69,
+	0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x68, 0x61, 0x73, 0x65, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x74, 0x63, 0x63, 0x2e, 0x70, 0x61, 0x72,
+	0x74, 0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x68, 0x61, 0x73,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4d, 0x0a, 0x06, 0x43, 0x61, 0x6e,
+	0x63, 0x65, 0x6c, 0x12, 0x20, 0x2e, 0x74, 0x63, 0x63, 0x2e, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63,
+	0x69, 0x70, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x68, 0x61, 0x73, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x74, 0x63, 0x63, 0x2e, 0x70, 0x61, 0x72, 0x74,
+	0x69, 0x63, 0x69, 0x70, 0x61, 0x6e, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x68, 0x61, 0x73, 0x65,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x26, 0x5a, 0x24, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x64, 0x6c, 0x6c, 0x65, 0x6e, 0x2f, 0x67, 0x2d, 0x74,
+	0x63, 0x63, 0x2f, 0x74, 0x63, 0x63, 0x67, 0x72, 0x70, 0x63, 0x2f, 0x74, 0x63, 0x63, 0x70, 0x62,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_participant_proto_rawDescOnce sync.Once
+	file_participant_proto_rawDescData = file_participant_proto_rawDesc
+)
+
+func file_participant_proto_rawDescGZIP() []byte {
+	file_participant_proto_rawDescOnce.Do(func() {
+		file_participant_proto_rawDescData = protoimpl.X.CompressGZIP(file_participant_proto_rawDescData)
+	})
+	return file_participant_proto_rawDescData
+}
+
+var file_participant_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_participant_proto_goTypes = []any{
+	(*PhaseRequest)(nil),  // 0: tcc.participant.v1.PhaseRequest
+	(*PhaseResponse)(nil), // 1: tcc.participant.v1.PhaseResponse
+}
+var file_participant_proto_depIdxs = []int32{
+	0, // 0: tcc.participant.v1.Participant.Try:input_type -> tcc.participant.v1.PhaseRequest
+	0, // 1: tcc.participant.v1.Participant.Confirm:input_type -> tcc.participant.v1.PhaseRequest
+	0, // 2: tcc.participant.v1.Participant.Cancel:input_type -> tcc.participant.v1.PhaseRequest
+	1, // 3: tcc.participant.v1.Participant.Try:output_type -> tcc.participant.v1.PhaseResponse
+	1, // 4: tcc.participant.v1.Participant.Confirm:output_type -> tcc.participant.v1.PhaseResponse
+	1, // 5: tcc.participant.v1.Participant.Cancel:output_type -> tcc.participant.v1.PhaseResponse
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_participant_proto_init() }
+func file_participant_proto_init() {
+	if File_participant_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_participant_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*PhaseRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_participant_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*PhaseResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_participant_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_participant_proto_goTypes,
+		DependencyIndexes: file_participant_proto_depIdxs,
+		MessageInfos:      file_participant_proto_msgTypes,
+	}.Build()
+	File_participant_proto = out.File
+	file_participant_proto_rawDesc = nil
+	file_participant_proto_goTypes = nil
+	file_participant_proto_depIdxs = nil
+}
@@ -0,0 +1,186 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: participant.proto
+
+package tccpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	Participant_Try_FullMethodName     = "/tcc.participant.v1.Participant/Try"
+	Participant_Confirm_FullMethodName = "/tcc.participant.v1.Participant/Confirm"
+	Participant_Cancel_FullMethodName  = "/tcc.participant.v1.Participant/Cancel"
+)
+
+// ParticipantClient is the client API for Participant service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ParticipantClient interface {
+	Try(ctx context.Context, in *PhaseRequest, opts ...grpc.CallOption) (*PhaseResponse, error)
+	Confirm(ctx context.Context, in *PhaseRequest, opts ...grpc.CallOption) (*PhaseResponse, error)
+	Cancel(ctx context.Context, in *PhaseRequest, opts ...grpc.CallOption) (*PhaseResponse, error)
+}
+
+type participantClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewParticipantClient(cc grpc.ClientConnInterface) ParticipantClient {
+	return &participantClient{cc}
+}
+
+func (c *participantClient) Try(ctx context.Context, in *PhaseRequest, opts ...grpc.CallOption) (*PhaseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PhaseResponse)
+	err := c.cc.Invoke(ctx, Participant_Try_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *participantClient) Confirm(ctx context.Context, in *PhaseRequest, opts ...grpc.CallOption) (*PhaseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PhaseResponse)
+	err := c.cc.Invoke(ctx, Participant_Confirm_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *participantClient) Cancel(ctx context.Context, in *PhaseRequest, opts ...grpc.CallOption) (*PhaseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PhaseResponse)
+	err := c.cc.Invoke(ctx, Participant_Cancel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ParticipantServer is the server API for Participant service.
+// All implementations must embed UnimplementedParticipantServer
+// for forward compatibility
+type ParticipantServer interface {
+	Try(context.Context, *PhaseRequest) (*PhaseResponse, error)
+	Confirm(context.Context, *PhaseRequest) (*PhaseResponse, error)
+	Cancel(context.Context, *PhaseRequest) (*PhaseResponse, error)
+	mustEmbedUnimplementedParticipantServer()
+}
+
+// UnimplementedParticipantServer must be embedded to have forward compatible implementations.
+type UnimplementedParticipantServer struct {
+}
+
+func (UnimplementedParticipantServer) Try(context.Context, *PhaseRequest) (*PhaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Try not implemented")
+}
+func (UnimplementedParticipantServer) Confirm(context.Context, *PhaseRequest) (*PhaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Confirm not implemented")
+}
+func (UnimplementedParticipantServer) Cancel(context.Context, *PhaseRequest) (*PhaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedParticipantServer) mustEmbedUnimplementedParticipantServer() {}
+
+// UnsafeParticipantServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ParticipantServer will
+// result in compilation errors.
+type UnsafeParticipantServer interface {
+	mustEmbedUnimplementedParticipantServer()
+}
+
+func RegisterParticipantServer(s grpc.ServiceRegistrar, srv ParticipantServer) {
+	s.RegisterService(&Participant_ServiceDesc, srv)
+}
+
+func _Participant_Try_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PhaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParticipantServer).Try(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Participant_Try_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParticipantServer).Try(ctx, req.(*PhaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Participant_Confirm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PhaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParticipantServer).Confirm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Participant_Confirm_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParticipantServer).Confirm(ctx, req.(*PhaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Participant_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PhaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParticipantServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Participant_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParticipantServer).Cancel(ctx, req.(*PhaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Participant_ServiceDesc is the grpc.ServiceDesc for Participant service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Participant_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tcc.participant.v1.Participant",
+	HandlerType: (*ParticipantServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Try",
+			Handler:    _Participant_Try_Handler,
+		},
+		{
+			MethodName: "Confirm",
+			Handler:    _Participant_Confirm_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _Participant_Cancel_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "participant.proto",
+}
@@ -0,0 +1,232 @@
+// Package tccredis provides Redis backed implementations for tcc.
+package tccredis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dllen/g-tcc"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultLockPrefix = "tcc:lock:"
+	defaultLockTTL    = time.Minute
+)
+
+var (
+	// lockScript locks all the KEYS for ARGV[1] with ttl ARGV[2] milliseconds, or none of them.
+	lockScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	local owner = redis.call("GET", key)
+	if owner and owner ~= ARGV[1] then
+		return 0
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call("SET", key, ARGV[1], "PX", ARGV[2])
+end
+return 1
+`)
+
+	// renewScript sets ttl ARGV[2] milliseconds of the KEYS owned by ARGV[1].
+	renewScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call("GET", key) == ARGV[1] then
+		redis.call("PEXPIRE", key, ARGV[2])
+	end
+end
+return 1
+`)
+
+	// unlockScript deletes the KEYS owned by ARGV[1].
+	unlockScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call("GET", key) == ARGV[1] then
+		redis.call("DEL", key)
+	end
+end
+return 1
+`)
+)
+
+// LockerOption can set option to ResourceLocker
+type LockerOption func(l *locker)
+
+// WithLockPrefix sets prefix of redis keys, "tcc:lock:" by default.
+func WithLockPrefix(prefix string) LockerOption {
+	return func(l *locker) {
+		l.prefix = prefix
+	}
+}
+
+// WithLockTTL sets how long the lock is kept if it is never released,
+// e.g. the coordinator crashed in the middle of the transaction, or the transaction failed. 1 minute by default.
+// Locks are renewed every third of the TTL until they are released or the director returns,
+// so it only has to be longer than the time this process may stop renewing them.
+func WithLockTTL(ttl time.Duration) LockerOption {
+	return func(l *locker) {
+		l.ttl = ttl
+	}
+}
+
+type locker struct {
+	client redis.Scripter
+	prefix string
+	ttl    time.Duration
+
+	mu sync.Mutex
+	// held are the keys locked by each transaction, renewed until they are released.
+	held map[string]*holding
+}
+
+// holding is the prefixed keys locked by a transaction, which are renewed until they are released,
+// stop is closed, or deadline passes unless it is zero.
+type holding struct {
+	keys     map[string]struct{}
+	deadline time.Time
+	stop     chan struct{}
+}
+
+// NewResourceLocker returns tcc.ResourceLocker which locks keys in redis.
+// Keys of a transaction are locked atomically by a lua script,
+// so with Redis Cluster they have to be in the same hash slot, e.g. by using hash tags in WithLockPrefix.
+// It implements tcc.ResourceLockRenewer, so locks never outlive the deadline of the transaction,
+// and locks kept by a failed transaction expire after the TTL.
+func NewResourceLocker(client redis.Scripter, opts ...LockerOption) tcc.ResourceLocker {
+	l := &locker{
+		client: client,
+		prefix: defaultLockPrefix,
+		ttl:    defaultLockTTL,
+		held:   map[string]*holding{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *locker) Lock(txId string, keys []string) error {
+	return l.LockUntil(txId, keys, time.Time{})
+}
+
+func (l *locker) LockUntil(txId string, keys []string, deadline time.Time) error {
+	prefixed := l.keys(keys)
+	locked, err := lockScript.Run(context.Background(), l.client, prefixed, txId, l.ttlUntil(deadline).Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if locked == 0 {
+		return tcc.ErrResourceBusy
+	}
+	l.hold(txId, prefixed, deadline)
+	return nil
+}
+
+func (l *locker) Unlock(txId string, keys []string) error {
+	prefixed := l.keys(keys)
+	l.release(txId, prefixed)
+	return unlockScript.Run(context.Background(), l.client, prefixed, txId).Err()
+}
+
+func (l *locker) StopRenewal(txId string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if h, ok := l.held[txId]; ok {
+		close(h.stop)
+		delete(l.held, txId)
+	}
+}
+
+// hold starts renewing the keys, with a goroutine for each transaction holding keys.
+func (l *locker) hold(txId string, keys []string, deadline time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.held[txId]
+	if !ok {
+		h = &holding{keys: map[string]struct{}{}, deadline: deadline, stop: make(chan struct{})}
+		l.held[txId] = h
+		go l.renew(txId, h)
+	}
+	if !deadline.IsZero() && (h.deadline.IsZero() || deadline.Before(h.deadline)) {
+		h.deadline = deadline
+	}
+	for _, key := range keys {
+		h.keys[key] = struct{}{}
+	}
+}
+
+// release stops renewing the keys, and the renewal goroutine once no key is held.
+func (l *locker) release(txId string, keys []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.held[txId]
+	if !ok {
+		return
+	}
+	for _, key := range keys {
+		delete(h.keys, key)
+	}
+	if len(h.keys) == 0 {
+		close(h.stop)
+		delete(l.held, txId)
+	}
+}
+
+// renew renews the keys held by the transaction until renewing h stops or its deadline passes.
+// Keys are renewed one by one, because keys of different services may be in different hash slots.
+// If renewing fails, it is tried again at the next tick, before the lock expires.
+func (l *locker) renew(txId string, h *holding) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+		l.mu.Lock()
+		ttl := l.ttlUntil(h.deadline)
+		if !h.deadline.IsZero() && !time.Now().Before(h.deadline) {
+			// the keys expire by the deadline
+			if l.held[txId] == h {
+				delete(l.held, txId)
+			}
+			l.mu.Unlock()
+			return
+		}
+		keys := make([]string, 0, len(h.keys))
+		for key := range h.keys {
+			keys = append(keys, key)
+		}
+		l.mu.Unlock()
+		for _, key := range keys {
+			_ = renewScript.Run(context.Background(), l.client, []string{key}, txId, ttl.Milliseconds()).Err()
+		}
+	}
+}
+
+// ttlUntil returns the ttl of locks, cut short to end by deadline unless it is zero.
+func (l *locker) ttlUntil(deadline time.Time) time.Duration {
+	if deadline.IsZero() {
+		return l.ttl
+	}
+	ttl := time.Until(deadline)
+	if ttl > l.ttl {
+		return l.ttl
+	}
+	if ttl < time.Millisecond {
+		// redis refuses a ttl of 0
+		return time.Millisecond
+	}
+	return ttl
+}
+
+func (l *locker) keys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = l.prefix + key
+	}
+	return prefixed
+}
@@ -0,0 +1,169 @@
+package tccredis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dllen/g-tcc"
+	"github.com/go-redis/redis/v8"
+)
+
+func newClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("cannot run miniredis: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s, redis.NewClient(&redis.Options{Addr: s.Addr()})
+}
+
+func Test_locker_Lock(t *testing.T) {
+	tests := []struct {
+		name    string
+		locked  map[string]string
+		txId    string
+		keys    []string
+		wantErr error
+	}{
+		{
+			name:    "free",
+			txId:    "tx1",
+			keys:    []string{"k1", "k2"},
+			wantErr: nil,
+		},
+		{
+			name:    "locked by same transaction",
+			locked:  map[string]string{"k1": "tx1"},
+			txId:    "tx1",
+			keys:    []string{"k1", "k2"},
+			wantErr: nil,
+		},
+		{
+			name:    "locked by other transaction",
+			locked:  map[string]string{"k2": "tx2"},
+			txId:    "tx1",
+			keys:    []string{"k1", "k2"},
+			wantErr: tcc.ErrResourceBusy,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, client := newClient(t)
+			for key, owner := range tt.locked {
+				_ = s.Set(defaultLockPrefix+key, owner)
+			}
+			l := NewResourceLocker(client)
+			err := l.Lock(tt.txId, tt.keys)
+			if err != tt.wantErr {
+				t.Errorf("locker.Lock() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, key := range tt.keys {
+				got, _ := s.Get(defaultLockPrefix + key)
+				if err == nil && got != tt.txId {
+					t.Errorf("key %v is locked by %q, want %q", key, got, tt.txId)
+				}
+				if err != nil && got != tt.locked[key] {
+					t.Errorf("key %v is locked partially", key)
+				}
+			}
+		})
+	}
+}
+
+func Test_locker_TTL(t *testing.T) {
+	s, client := newClient(t)
+	l := NewResourceLocker(client, WithLockTTL(time.Second), WithLockPrefix("test:"))
+	if err := l.Lock("tx1", []string{"k1"}); err != nil {
+		t.Fatalf("locker.Lock() error = %v", err)
+	}
+	if ttl := s.TTL("test:k1"); ttl != time.Second {
+		t.Errorf("ttl = %v, want %v", ttl, time.Second)
+	}
+	s.FastForward(2 * time.Second)
+	if err := l.Lock("tx2", []string{"k1"}); err != nil {
+		t.Errorf("expired lock is not released: %v", err)
+	}
+}
+
+func Test_locker_Unlock(t *testing.T) {
+	s, client := newClient(t)
+	_ = s.Set(defaultLockPrefix+"k1", "tx1")
+	_ = s.Set(defaultLockPrefix+"k2", "tx2")
+	l := NewResourceLocker(client)
+	if err := l.Unlock("tx1", []string{"k1", "k2"}); err != nil {
+		t.Errorf("locker.Unlock() error = %v", err)
+	}
+	if s.Exists(defaultLockPrefix + "k1") {
+		t.Errorf("k1 is not released")
+	}
+	if got, _ := s.Get(defaultLockPrefix + "k2"); got != "tx2" {
+		t.Errorf("k2 locked by other transaction is released")
+	}
+}
+
+func Test_locker_renew(t *testing.T) {
+	s, client := newClient(t)
+	l := NewResourceLocker(client, WithLockTTL(30*time.Millisecond))
+	if err := l.Lock("tx1", []string{"k1"}); err != nil {
+		t.Fatalf("locker.Lock() error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		// miniredis expires keys only when the clock is fast forwarded
+		s.FastForward(20 * time.Millisecond)
+		if got, _ := s.Get(defaultLockPrefix + "k1"); got != "tx1" {
+			t.Fatalf("held lock expired after %d renewals", i)
+		}
+	}
+	if err := l.Unlock("tx1", []string{"k1"}); err != nil {
+		t.Fatalf("locker.Unlock() error = %v", err)
+	}
+	_ = s.Set(defaultLockPrefix+"k1", "tx1")
+	s.SetTTL(defaultLockPrefix+"k1", 30*time.Millisecond)
+	time.Sleep(40 * time.Millisecond)
+	s.FastForward(40 * time.Millisecond)
+	if s.Exists(defaultLockPrefix + "k1") {
+		t.Errorf("released lock is still renewed")
+	}
+}
+
+func Test_locker_StopRenewal(t *testing.T) {
+	s, client := newClient(t)
+	l := NewResourceLocker(client, WithLockTTL(30*time.Millisecond)).(*locker)
+	if err := l.Lock("tx1", []string{"k1"}); err != nil {
+		t.Fatalf("locker.Lock() error = %v", err)
+	}
+	l.StopRenewal("tx1")
+	if len(l.held) != 0 {
+		t.Errorf("locker holds %v after renewal stopped", l.held)
+	}
+	time.Sleep(40 * time.Millisecond)
+	s.FastForward(40 * time.Millisecond)
+	if s.Exists(defaultLockPrefix + "k1") {
+		t.Errorf("lock is still renewed after renewal stopped")
+	}
+}
+
+func Test_locker_LockUntil(t *testing.T) {
+	s, client := newClient(t)
+	l := NewResourceLocker(client, WithLockTTL(time.Minute)).(*locker)
+	deadline := time.Now().Add(100 * time.Millisecond)
+	if err := l.LockUntil("tx1", []string{"k1"}, deadline); err != nil {
+		t.Fatalf("locker.LockUntil() error = %v", err)
+	}
+	if ttl := s.TTL(defaultLockPrefix + "k1"); ttl <= 0 || ttl > 100*time.Millisecond {
+		t.Errorf("ttl = %v, want at most %v", ttl, 100*time.Millisecond)
+	}
+	s.FastForward(100 * time.Millisecond)
+	if s.Exists(defaultLockPrefix + "k1") {
+		t.Errorf("lock outlives the deadline")
+	}
+	if err := l.Unlock("tx1", []string{"k1"}); err != nil {
+		t.Fatalf("locker.Unlock() error = %v", err)
+	}
+	if len(l.held) != 0 {
+		t.Errorf("locker holds %v after unlock", l.held)
+	}
+}
@@ -0,0 +1,335 @@
+package tccredis
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/dllen/g-tcc"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	defaultStorePrefix = "tcc:"
+	defaultStoreTTL    = time.Minute
+	defaultFinishedTTL = 24 * time.Hour
+	defaultClaimLimit  = 100
+)
+
+var (
+	// saveScript replaces the transaction hash KEYS[1]. If it is pending (ARGV[3] == "1"),
+	// it is added to the pending set KEYS[2] with score ARGV[4] and to the started set KEYS[3] with score ARGV[7],
+	// otherwise it is removed from them and expires after ARGV[5] milliseconds.
+	// ARGV[8:] are name, try, confirm and cancel flags, try result, and isolation hint of each service.
+	saveScript = redis.NewScript(`
+redis.call("DEL", KEYS[1])
+redis.call("HSET", KEYS[1], "status", ARGV[2], "services", ARGV[6], "started", ARGV[7])
+for i = 8, #ARGV, 6 do
+	local name = ARGV[i]
+	redis.call("HSET", KEYS[1], "svc:" .. name, "1")
+	if ARGV[i + 1] == "1" then redis.call("HSET", KEYS[1], "done:try:" .. name, "1") end
+	if ARGV[i + 2] == "1" then redis.call("HSET", KEYS[1], "done:confirm:" .. name, "1") end
+	if ARGV[i + 3] == "1" then redis.call("HSET", KEYS[1], "done:cancel:" .. name, "1") end
+	if ARGV[i + 4] ~= "" then redis.call("HSET", KEYS[1], "result:" .. name, ARGV[i + 4]) end
+	if ARGV[i + 5] ~= "" then redis.call("HSET", KEYS[1], "isolation:" .. name, ARGV[i + 5]) end
+end
+if ARGV[3] == "1" then
+	redis.call("ZADD", KEYS[2], ARGV[4], ARGV[1])
+	redis.call("ZADD", KEYS[3], ARGV[7], ARGV[1])
+else
+	redis.call("ZREM", KEYS[2], ARGV[1])
+	redis.call("ZREM", KEYS[3], ARGV[1])
+	redis.call("PEXPIRE", KEYS[1], ARGV[5])
+end
+return 1
+`)
+
+	// markScript marks phase ARGV[2] of service ARGV[1] done in the transaction hash KEYS[1],
+	// or returns 0 if the service is not saved.
+	markScript = redis.NewScript(`
+if redis.call("HEXISTS", KEYS[1], "svc:" .. ARGV[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "done:" .. ARGV[2] .. ":" .. ARGV[1], "1")
+return 1
+`)
+
+	// claimScript returns transactions in the pending set KEYS[1] whose ttl expired by ARGV[1],
+	// at most ARGV[3] of them unless it is 0, extending their ttl to ARGV[2].
+	claimScript = redis.NewScript(`
+local ids
+if tonumber(ARGV[3]) > 0 then
+	ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[3])
+else
+	ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1])
+end
+for _, id in ipairs(ids) do
+	redis.call("ZADD", KEYS[1], ARGV[2], id)
+end
+return ids
+`)
+
+	// claimOneScript extends the ttl of transaction ARGV[1] in the pending set KEYS[1] to ARGV[2],
+	// or returns 0 if it is not pending.
+	claimOneScript = redis.NewScript(`
+if not redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+return 1
+`)
+)
+
+// StoreOption can set option to Store
+type StoreOption func(s *store)
+
+// WithStorePrefix sets prefix of redis keys, "tcc:" by default.
+func WithStorePrefix(prefix string) StoreOption {
+	return func(s *store) {
+		s.prefix = prefix
+	}
+}
+
+// WithStoreTTL sets how long a pending transaction is owned by the coordinator which saved or loaded it last.
+// 1 minute by default. A reaper picks up the transaction only after it expires,
+// so it should be longer than a transaction takes between saves, including retries of confirm and cancel.
+func WithStoreTTL(ttl time.Duration) StoreOption {
+	return func(s *store) {
+		s.ttl = ttl
+	}
+}
+
+// WithFinishedTTL sets how long a confirmed, canceled or failed transaction is kept. 24 hours by default.
+func WithFinishedTTL(ttl time.Duration) StoreOption {
+	return func(s *store) {
+		s.finishedTTL = ttl
+	}
+}
+
+// WithClaimLimit sets how many transactions LoadPendingTx claims at most, 100 by default, and 0 means no limit.
+// Every claimed transaction gets its ttl extended at once, and tcc.Recover recovers them one by one,
+// so the limit should be small enough for a batch to be recovered before the ttl expires;
+// otherwise the rest of the batch is claimed again by other reapers while it is still recovered.
+// The rest of a large backlog is claimed by the next rounds of Reap.
+func WithClaimLimit(limit int) StoreOption {
+	return func(s *store) {
+		s.claimLimit = limit
+	}
+}
+
+type store struct {
+	client      redis.Cmdable
+	prefix      string
+	ttl         time.Duration
+	finishedTTL time.Duration
+	claimLimit  int
+	now         func() time.Time
+}
+
+// NewStore returns tcc.Store which saves transactions in redis.
+// Each save and phase transition is atomic by a lua script.
+// Pending transactions are kept in a sorted set scored by the time their ttl expires,
+// and LoadPendingTx only returns expired ones, extending their ttl atomically,
+// so coordinators sharing the redis never recover the same transaction at the same time.
+// Finished transactions are kept for WithFinishedTTL, so that GetTx finds them.
+// The store implements tcc.Getter, tcc.Claimer and tcc.PendingInspector.
+// With Redis Cluster keys need to be in the same hash slot, e.g. by using hash tags in WithStorePrefix.
+func NewStore(client redis.Cmdable, opts ...StoreOption) tcc.Store {
+	s := &store{
+		client:      client,
+		prefix:      defaultStorePrefix,
+		ttl:         defaultStoreTTL,
+		finishedTTL: defaultFinishedTTL,
+		claimLimit:  defaultClaimLimit,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reap recovers transactions abandoned in store every interval until ctx is done,
+// passing services and opts to tcc.Recover.
+// A transaction which fails to recover is picked up again after its ttl expires.
+func Reap(ctx context.Context, store tcc.Store, services []*tcc.Service, interval time.Duration, opts ...tcc.Option) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_ = tcc.Recover(ctx, store, services, opts...)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *store) SaveTxState(ctx context.Context, state *tcc.TxState) error {
+	names := make([]string, len(state.Services))
+	for i, ss := range state.Services {
+		names[i] = ss.Name
+	}
+	encoded, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	args := []interface{}{
+		state.TxId,
+		int(state.Status),
+		flag(pending(state.Status)),
+		s.expiresAt(),
+		s.finishedTTL.Milliseconds(),
+		string(encoded),
+		millis(state.StartedAt),
+	}
+	for _, ss := range state.Services {
+		args = append(args, ss.Name, flag(ss.TrySucceeded), flag(ss.ConfirmSucceeded), flag(ss.CancelSucceeded), ss.TryResult, string(ss.Isolation))
+	}
+	return saveScript.Run(ctx, s.client, []string{s.txKey(state.TxId), s.pendingKey(), s.startedKey()}, args...).Err()
+}
+
+func (s *store) MarkPhaseDone(ctx context.Context, txId, serviceName string, phase tcc.Phase) error {
+	marked, err := markScript.Run(ctx, s.client, []string{s.txKey(txId)}, serviceName, string(phase)).Int()
+	if err != nil {
+		return err
+	}
+	if marked == 0 {
+		return tcc.ErrTxNotFound
+	}
+	return nil
+}
+
+func (s *store) LoadPendingTx(ctx context.Context) ([]*tcc.TxState, error) {
+	ids, err := claimScript.Run(ctx, s.client, []string{s.pendingKey()}, millis(s.now()), s.expiresAt(), s.claimLimit).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+	var states []*tcc.TxState
+	for _, id := range ids {
+		fields, err := s.client.HGetAll(ctx, s.txKey(id)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			// deleted by someone else, e.g. the operator
+			_ = s.client.ZRem(ctx, s.pendingKey(), id).Err()
+			_ = s.client.ZRem(ctx, s.startedKey(), id).Err()
+			continue
+		}
+		state, err := decodeTxState(id, fields)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (s *store) GetTx(ctx context.Context, txId string) (*tcc.TxState, error) {
+	fields, err := s.client.HGetAll(ctx, s.txKey(txId)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, tcc.ErrTxNotFound
+	}
+	return decodeTxState(txId, fields)
+}
+
+func (s *store) ClaimTx(ctx context.Context, txId string) (*tcc.TxState, error) {
+	claimed, err := claimOneScript.Run(ctx, s.client, []string{s.pendingKey()}, txId, s.expiresAt()).Int()
+	if err != nil {
+		return nil, err
+	}
+	if claimed == 0 {
+		return nil, tcc.ErrTxNotFound
+	}
+	return s.GetTx(ctx, txId)
+}
+
+func (s *store) PendingStartTimes(ctx context.Context) ([]time.Time, error) {
+	started, err := s.client.ZRangeWithScores(ctx, s.startedKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	startTimes := make([]time.Time, len(started))
+	for i, z := range started {
+		startTimes[i] = fromMillis(int64(z.Score))
+	}
+	return startTimes, nil
+}
+
+func decodeTxState(txId string, fields map[string]string) (*tcc.TxState, error) {
+	status, err := strconv.Atoi(fields["status"])
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(fields["services"]), &names); err != nil {
+		return nil, err
+	}
+	startedAt, err := strconv.ParseInt(fields["started"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	state := &tcc.TxState{TxId: txId, Status: tcc.Status(status), StartedAt: fromMillis(startedAt)}
+	for _, name := range names {
+		ss := tcc.ServiceState{
+			Name:             name,
+			TrySucceeded:     fields["done:try:"+name] == "1",
+			ConfirmSucceeded: fields["done:confirm:"+name] == "1",
+			CancelSucceeded:  fields["done:cancel:"+name] == "1",
+			Isolation:        tcc.Isolation(fields["isolation:"+name]),
+		}
+		if result, ok := fields["result:"+name]; ok {
+			ss.TryResult = []byte(result)
+		}
+		state.Services = append(state.Services, ss)
+	}
+	return state, nil
+}
+
+// expiresAt returns unix time in milliseconds when the ttl taken now expires.
+func (s *store) expiresAt() int64 {
+	return millis(s.now().Add(s.ttl))
+}
+
+func (s *store) txKey(txId string) string {
+	return s.prefix + "tx:" + txId
+}
+
+func (s *store) pendingKey() string {
+	return s.prefix + "pending"
+}
+
+func (s *store) startedKey() string {
+	return s.prefix + "started"
+}
+
+func pending(status tcc.Status) bool {
+	return status == tcc.StatusTrying || status == tcc.StatusConfirming || status == tcc.StatusCanceling
+}
+
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// millis returns unix time of t in milliseconds, or 0 for zero t.
+func millis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
@@ -0,0 +1,160 @@
+package tccredis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dllen/g-tcc"
+)
+
+func Test_store(t *testing.T) {
+	ctx := context.Background()
+	s, client := newClient(t)
+	now := time.Unix(1000, 0)
+	store := NewStore(client).(*store)
+	store.now = func() time.Time { return now }
+
+	err := store.SaveTxState(ctx, &tcc.TxState{
+		TxId:      "tx1",
+		Status:    tcc.StatusConfirming,
+		StartedAt: time.Unix(900, 0),
+		Services: []tcc.ServiceState{
+			{Name: "s1", TrySucceeded: true, TryResult: []byte("r1")},
+			{Name: "s2", TrySucceeded: true, Isolation: tcc.IsolationLock},
+		},
+	})
+	if err != nil {
+		t.Fatalf("store.SaveTxState() error = %v", err)
+	}
+	if err := store.MarkPhaseDone(ctx, "tx1", "s2", tcc.PhaseConfirm); err != nil {
+		t.Errorf("store.MarkPhaseDone() error = %v", err)
+	}
+	if err := store.MarkPhaseDone(ctx, "tx1", "s3", tcc.PhaseConfirm); !errors.Is(err, tcc.ErrTxNotFound) {
+		t.Errorf("store.MarkPhaseDone() error = %v, want %v", err, tcc.ErrTxNotFound)
+	}
+
+	states, err := store.LoadPendingTx(ctx)
+	if err != nil || len(states) != 0 {
+		t.Errorf("store.LoadPendingTx() before ttl = %v, %v, want none", states, err)
+	}
+
+	now = now.Add(defaultStoreTTL)
+	states, err = store.LoadPendingTx(ctx)
+	if err != nil {
+		t.Fatalf("store.LoadPendingTx() error = %v", err)
+	}
+	if got, err := store.PendingStartTimes(ctx); err != nil || !reflect.DeepEqual(got, []time.Time{time.Unix(900, 0)}) {
+		t.Errorf("store.PendingStartTimes() = %v, %v", got, err)
+	}
+
+	want := []*tcc.TxState{{
+		TxId:      "tx1",
+		Status:    tcc.StatusConfirming,
+		StartedAt: time.Unix(900, 0),
+		Services: []tcc.ServiceState{
+			{Name: "s1", TrySucceeded: true, TryResult: []byte("r1")},
+			{Name: "s2", TrySucceeded: true, ConfirmSucceeded: true, Isolation: tcc.IsolationLock},
+		},
+	}}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("store.LoadPendingTx() = %+v, want %+v", states, want)
+	}
+	if states, _ := store.LoadPendingTx(ctx); len(states) != 0 {
+		t.Errorf("store.LoadPendingTx() of claimed transaction = %+v, want none", states)
+	}
+	if got, err := store.GetTx(ctx, "tx1"); err != nil || !reflect.DeepEqual(got, want[0]) {
+		t.Errorf("store.GetTx() = %+v, %v, want %+v", got, err, want[0])
+	}
+	if got, err := store.ClaimTx(ctx, "tx1"); err != nil || !reflect.DeepEqual(got, want[0]) {
+		t.Errorf("store.ClaimTx() of leased transaction = %+v, %v, want %+v", got, err, want[0])
+	}
+	if score, _ := s.ZScore(defaultStorePrefix+"pending", "tx1"); score != float64(millis(now.Add(defaultStoreTTL))) {
+		t.Errorf("ttl of claimed transaction = %v", score)
+	}
+	if _, err := store.GetTx(ctx, "tx2"); !errors.Is(err, tcc.ErrTxNotFound) {
+		t.Errorf("store.GetTx() of unknown transaction error = %v, want %v", err, tcc.ErrTxNotFound)
+	}
+
+	err = store.SaveTxState(ctx, &tcc.TxState{TxId: "tx1", Status: tcc.StatusConfirmed})
+	if err != nil {
+		t.Fatalf("store.SaveTxState() error = %v", err)
+	}
+	if ttl := s.TTL(defaultStorePrefix + "tx:tx1"); ttl != defaultFinishedTTL {
+		t.Errorf("ttl of finished transaction = %v, want %v", ttl, defaultFinishedTTL)
+	}
+	if got, _ := store.PendingStartTimes(ctx); len(got) != 0 {
+		t.Errorf("store.PendingStartTimes() of finished transaction = %v, want none", got)
+	}
+	if _, err := store.ClaimTx(ctx, "tx1"); !errors.Is(err, tcc.ErrTxNotFound) {
+		t.Errorf("store.ClaimTx() of finished transaction error = %v, want %v", err, tcc.ErrTxNotFound)
+	}
+	if got, err := store.GetTx(ctx, "tx1"); err != nil || got.Status != tcc.StatusConfirmed {
+		t.Errorf("store.GetTx() of finished transaction = %+v, %v", got, err)
+	}
+	now = now.Add(2 * defaultStoreTTL)
+	if states, _ := store.LoadPendingTx(ctx); len(states) != 0 {
+		t.Errorf("store.LoadPendingTx() of finished transaction = %+v, want none", states)
+	}
+}
+
+func Test_store_LoadPendingTx_ClaimLimit(t *testing.T) {
+	ctx := context.Background()
+	_, client := newClient(t)
+	now := time.Unix(1000, 0)
+	store := NewStore(client, WithClaimLimit(2)).(*store)
+	store.now = func() time.Time { return now }
+	for _, id := range []string{"tx1", "tx2", "tx3"} {
+		if err := store.SaveTxState(ctx, &tcc.TxState{TxId: id, Status: tcc.StatusTrying}); err != nil {
+			t.Fatalf("store.SaveTxState() error = %v", err)
+		}
+	}
+
+	now = now.Add(defaultStoreTTL)
+	var got []string
+	for i := 0; i < 3; i++ {
+		states, err := store.LoadPendingTx(ctx)
+		if err != nil {
+			t.Fatalf("store.LoadPendingTx() error = %v", err)
+		}
+		if len(states) > 2 {
+			t.Errorf("store.LoadPendingTx() claimed %v transactions, want at most %v", len(states), 2)
+		}
+		for _, state := range states {
+			got = append(got, state.TxId)
+		}
+	}
+	if want := []string{"tx1", "tx2", "tx3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("claimed %v, want %v", got, want)
+	}
+}
+
+func TestReap(t *testing.T) {
+	_, client := newClient(t)
+	store := NewStore(client, WithStoreTTL(-time.Second))
+	err := store.SaveTxState(context.Background(), &tcc.TxState{
+		TxId:     "tx1",
+		Status:   tcc.StatusConfirming,
+		Services: []tcc.ServiceState{{Name: "s1", TrySucceeded: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed := make(chan string, 1)
+	services := []*tcc.Service{tcc.NewService(
+		"s1",
+		func() error { return nil },
+		func() error { confirmed <- "s1"; return nil },
+		func() error { return nil },
+	)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = Reap(ctx, store, services, time.Millisecond) }()
+	select {
+	case <-confirmed:
+	case <-time.After(time.Second):
+		t.Fatal("abandoned transaction is not confirmed by Reap()")
+	}
+}
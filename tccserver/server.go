@@ -0,0 +1,530 @@
+// Package tccserver provides a REST API running the coordinator as a standalone service,
+// directing transactions of participants registered by their HTTP endpoints.
+package tccserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dllen/g-tcc"
+	"github.com/dllen/g-tcc/httpservice"
+)
+
+// maxRequestSize is the size of request bodies read at most, so that a client cannot exhaust memory.
+const maxRequestSize = 1 << 20
+
+// Participant is a participant registered by PUT /participants, called by httpservice.
+type Participant struct {
+	Name    string `json:"name"`
+	Try     string `json:"try"`
+	Confirm string `json:"confirm"`
+	Cancel  string `json:"cancel"`
+}
+
+// Transaction is a transaction returned by the API.
+type Transaction struct {
+	TxId     string               `json:"tx_id"`
+	Status   string               `json:"status"`
+	Services []TransactionService `json:"services"`
+
+	// Incidents are names of the participants of the transaction with an open incident.
+	Incidents []string `json:"incidents,omitempty"`
+}
+
+// TransactionService is the progress of a participant in Transaction.
+type TransactionService struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// Incident is a participant incident returned by the API.
+type Incident struct {
+	Service  string    `json:"service"`
+	OpenedAt time.Time `json:"opened_at"`
+	Failures int       `json:"failures"`
+	TxIds    []string  `json:"tx_ids"`
+}
+
+// Option can set option to the server.
+type Option func(s *Server)
+
+// WithWorkers sets how many transactions are directed at the same time, and how many wait in the queue,
+// 16 and 1024 by default.
+func WithWorkers(workers, queueSize int) Option {
+	return func(s *Server) {
+		s.workers = workers
+		s.queueSize = queueSize
+	}
+}
+
+// WithDirectorOptions sets tcc.Option of the director of every transaction, e.g. tcc.WithMaxRetries.
+func WithDirectorOptions(opts ...tcc.Option) Option {
+	return func(s *Server) {
+		s.opts = append(s.opts, opts...)
+	}
+}
+
+// WithServiceOptions sets httpservice.Option of every participant, e.g. httpservice.WithClient.
+func WithServiceOptions(opts ...httpservice.Option) Option {
+	return func(s *Server) {
+		s.serviceOpts = append(s.serviceOpts, opts...)
+	}
+}
+
+// WithIncidents tracks incidents of participants with tcc.IncidentTracker,
+// opened when threshold transactions failed on a participant within window, calling onOpen once for the incident.
+// When the incident is resolved, pending transactions claimable from the store are confirmed or canceled again,
+// the ones including the participant first.
+func WithIncidents(window time.Duration, threshold int, onOpen func(tcc.Incident)) Option {
+	return func(s *Server) {
+		s.incidents = tcc.NewIncidentTracker(window, threshold, onOpen, tcc.WithOnResolve(s.redrive))
+	}
+}
+
+// Server is http.Handler serving the API:
+//
+//	PUT  /participants                 registers Participant, replacing the one with the same name
+//	GET  /participants                 lists participants
+//	POST /transactions                 starts a transaction of {"participants": [names]}, returning Transaction
+//	GET  /transactions/{id}            returns Transaction
+//	POST /transactions/{id}/retry      confirms or cancels the pending transaction again with tcc.RecoverTx
+//	POST /transactions/{id}/resolve    saves the pending transaction as {"status": "confirmed" or "canceled"},
+//	                                   after it is fixed by hand
+//	GET  /incidents                    lists open incidents with WithIncidents
+//	POST /incidents/{name}/resolve     resolves the incident of the participant by hand
+//
+// Participants are kept in memory, and have to be registered again after a restart
+// before pending transactions are retried or recovered.
+type Server struct {
+	store       tcc.Store
+	workers     int
+	queueSize   int
+	opts        []tcc.Option
+	serviceOpts []httpservice.Option
+	coordinator *tcc.Coordinator
+	incidents   *tcc.IncidentTracker
+
+	mu           sync.Mutex
+	participants map[string]Participant
+	// inflight are transactions started by the server which are not finished yet
+	inflight map[string]tcc.TxHandle
+}
+
+// NewServer returns Server which saves transactions to store.
+// Transactions which are not in flight are looked up in store without claiming them,
+// so store has to implement tcc.Getter or tcc.Lister. If it implements tcc.Claimer,
+// retry and resolve claim only the transaction they change.
+func NewServer(store tcc.Store, opts ...Option) *Server {
+	s := &Server{
+		store:        store,
+		workers:      16,
+		queueSize:    1024,
+		participants: map[string]Participant{},
+		inflight:     map[string]tcc.TxHandle{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.incidents != nil {
+		s.opts = append(s.opts, tcc.WithIncidentTracker(s.incidents))
+	}
+	s.coordinator = tcc.NewCoordinator(s.workers, s.queueSize, append(s.opts, tcc.WithStore(store))...)
+	return s
+}
+
+// Shutdown stops accepting transactions, and waits until every started transaction finishes like tcc.Coordinator.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.coordinator.Shutdown(ctx)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	switch {
+	case path == "participants" && r.Method == http.MethodPut:
+		s.register(w, r)
+	case path == "participants" && r.Method == http.MethodGet:
+		s.listParticipants(w)
+	case path == "transactions" && r.Method == http.MethodPost:
+		s.start(w, r)
+	case strings.HasPrefix(path, "transactions/"):
+		parts := strings.Split(strings.TrimPrefix(path, "transactions/"), "/")
+		switch {
+		case len(parts) == 1 && r.Method == http.MethodGet:
+			s.get(w, r, parts[0])
+		case len(parts) == 2 && parts[1] == "retry" && r.Method == http.MethodPost:
+			s.retry(w, r, parts[0])
+		case len(parts) == 2 && parts[1] == "resolve" && r.Method == http.MethodPost:
+			s.resolve(w, r, parts[0])
+		default:
+			writeError(w, http.StatusNotFound, errors.New("not found"))
+		}
+	case path == "incidents" && r.Method == http.MethodGet:
+		s.listIncidents(w)
+	case strings.HasPrefix(path, "incidents/") && strings.HasSuffix(path, "/resolve") && r.Method == http.MethodPost:
+		s.resolveIncident(w, strings.TrimSuffix(strings.TrimPrefix(path, "incidents/"), "/resolve"))
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	var p Participant
+	if !decode(w, r, &p) {
+		return
+	}
+	if p.Name == "" || p.Try == "" || p.Confirm == "" || p.Cancel == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name, try, confirm and cancel are required"))
+		return
+	}
+	s.mu.Lock()
+	s.participants[p.Name] = p
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listParticipants(w http.ResponseWriter) {
+	s.mu.Lock()
+	participants := make([]Participant, 0, len(s.participants))
+	for _, p := range s.participants {
+		participants = append(participants, p)
+	}
+	s.mu.Unlock()
+	sort.Slice(participants, func(i, j int) bool { return participants[i].Name < participants[j].Name })
+	writeJSON(w, http.StatusOK, participants)
+}
+
+func (s *Server) start(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Participants []string `json:"participants"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	if len(req.Participants) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("participants are required"))
+		return
+	}
+	// progress of services is saved by name, so a participant cannot be in a transaction twice
+	seen := map[string]bool{}
+	for _, name := range req.Participants {
+		if seen[name] {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("participant is duplicated: %s", name))
+			return
+		}
+		seen[name] = true
+	}
+	services, err := s.services(req.Participants)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// the handle is registered before the transaction can finish and remove it
+	s.mu.Lock()
+	h, err := s.coordinator.Submit(services...)
+	if err == nil {
+		s.inflight[h.TxId()] = h
+	}
+	s.mu.Unlock()
+	if errors.Is(err, tcc.ErrQueueFull) || errors.Is(err, tcc.ErrCoordinatorClosed) {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	go func() {
+		_ = h.Wait()
+		s.mu.Lock()
+		delete(s.inflight, h.TxId())
+		s.mu.Unlock()
+	}()
+	writeJSON(w, http.StatusAccepted, s.link(fromState(h.State())))
+}
+
+// services returns services of the named participants, or every registered participant if names is nil.
+func (s *Server) services(names []string) ([]*tcc.Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if names == nil {
+		for name := range s.participants {
+			names = append(names, name)
+		}
+	}
+	services := make([]*tcc.Service, len(names))
+	for i, name := range names {
+		p, ok := s.participants[name]
+		if !ok {
+			return nil, fmt.Errorf("participant is not registered: %s", name)
+		}
+		services[i] = httpservice.NewService(p.Name, p.Try, p.Confirm, p.Cancel, s.serviceOpts...)
+	}
+	return services, nil
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request, txId string) {
+	s.mu.Lock()
+	h, ok := s.inflight[txId]
+	s.mu.Unlock()
+	if ok {
+		writeJSON(w, http.StatusOK, s.link(fromState(h.State())))
+		return
+	}
+	state, err := s.find(r.Context(), txId)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.link(fromTxState(state)))
+}
+
+// find returns the transaction saved in store without claiming it.
+func (s *Server) find(ctx context.Context, txId string) (*tcc.TxState, error) {
+	if getter, ok := s.store.(tcc.Getter); ok {
+		return getter.GetTx(ctx, txId)
+	}
+	if _, ok := s.store.(tcc.Lister); !ok {
+		return nil, fmt.Errorf("%T implements neither tcc.Getter nor tcc.Lister", s.store)
+	}
+	errFound := errors.New("found")
+	var found *tcc.TxState
+	err := tcc.EachTx(ctx, s.store, 100, func(state *tcc.TxState) error {
+		if state.TxId != txId {
+			return nil
+		}
+		found = state
+		return errFound
+	})
+	if found != nil {
+		return found, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, tcc.ErrTxNotFound
+}
+
+// claim claims the pending transaction if store implements tcc.Claimer, otherwise finds it.
+func (s *Server) claim(ctx context.Context, txId string) (*tcc.TxState, error) {
+	if claimer, ok := s.store.(tcc.Claimer); ok {
+		return claimer.ClaimTx(ctx, txId)
+	}
+	state, err := s.find(ctx, txId)
+	if err != nil {
+		return nil, err
+	}
+	switch state.Status {
+	case tcc.StatusTrying, tcc.StatusConfirming, tcc.StatusCanceling:
+		return state, nil
+	default:
+		return nil, tcc.ErrTxNotFound
+	}
+}
+
+func (s *Server) retry(w http.ResponseWriter, r *http.Request, txId string) {
+	s.mu.Lock()
+	_, ok := s.inflight[txId]
+	s.mu.Unlock()
+	if ok {
+		writeError(w, http.StatusConflict, errors.New("transaction is in flight"))
+		return
+	}
+	// tcc.RecoverTx claims only the transaction if store implements tcc.Claimer
+	services, err := s.services(nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = tcc.RecoverTx(r.Context(), s.store, txId, services, s.opts...)
+	var ce *tcc.CoordinatorError
+	switch {
+	case errors.Is(err, tcc.ErrTxNotFound) || errors.Is(err, tcc.ErrUnknownService):
+		writeStoreError(w, err)
+		return
+	case errors.As(err, &ce):
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// if participants failed again, the transaction is returned still pending
+	s.get(w, r, txId)
+}
+
+func (s *Server) resolve(w http.ResponseWriter, r *http.Request, txId string) {
+	s.mu.Lock()
+	_, ok := s.inflight[txId]
+	s.mu.Unlock()
+	if ok {
+		writeError(w, http.StatusConflict, errors.New("transaction is in flight"))
+		return
+	}
+	var req struct {
+		Status string `json:"status"`
+	}
+	if !decode(w, r, &req) {
+		return
+	}
+	var status tcc.Status
+	switch req.Status {
+	case tcc.StatusConfirmed.String():
+		status = tcc.StatusConfirmed
+	case tcc.StatusCanceled.String():
+		status = tcc.StatusCanceled
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("status must be confirmed or canceled: %q", req.Status))
+		return
+	}
+	state, err := s.claim(r.Context(), txId)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	state.Status = status
+	if err := s.store.SaveTxState(r.Context(), state); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.link(fromTxState(state)))
+}
+
+func (s *Server) listIncidents(w http.ResponseWriter) {
+	incidents := []Incident{}
+	if s.incidents != nil {
+		for _, i := range s.incidents.Incidents() {
+			incidents = append(incidents, Incident{Service: i.Service, OpenedAt: i.OpenedAt, Failures: i.Failures, TxIds: i.TxIds})
+		}
+	}
+	writeJSON(w, http.StatusOK, incidents)
+}
+
+func (s *Server) resolveIncident(w http.ResponseWriter, name string) {
+	if s.incidents == nil || !s.incidents.Resolve(name) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no open incident of participant: %s", name))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// redrive confirms or cancels again the pending transactions claimed from store when the incident is resolved,
+// the ones including the participant of the incident first.
+// Every claimed transaction is recovered, because a leasing store does not give the others to anyone until the lease expires.
+func (s *Server) redrive(incident tcc.Incident) {
+	go func() {
+		ctx := context.Background()
+		states, err := s.store.LoadPendingTx(ctx)
+		if err != nil {
+			return
+		}
+		services, err := s.services(nil)
+		if err != nil {
+			return
+		}
+		sort.SliceStable(states, func(i, j int) bool {
+			return includes(states[i], incident.Service) && !includes(states[j], incident.Service)
+		})
+		for _, state := range states {
+			s.mu.Lock()
+			_, inflight := s.inflight[state.TxId]
+			s.mu.Unlock()
+			if inflight {
+				continue
+			}
+			// errors are left for the next re-drive or retry
+			_ = tcc.RecoverState(ctx, s.store, state, services, s.opts...)
+		}
+	}()
+}
+
+func includes(state *tcc.TxState, name string) bool {
+	for _, ss := range state.Services {
+		if ss.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// link adds the open incidents of the participants to the transaction.
+func (s *Server) link(t *Transaction) *Transaction {
+	if s.incidents == nil {
+		return t
+	}
+	for _, ts := range t.Services {
+		if _, ok := s.incidents.Incident(ts.Name); ok {
+			t.Incidents = append(t.Incidents, ts.Name)
+		}
+	}
+	return t
+}
+
+func fromState(state *tcc.State) *Transaction {
+	t := &Transaction{TxId: state.TxId, Status: state.Status.String(), Services: []TransactionService{}}
+	for _, p := range state.Services {
+		t.Services = append(t.Services, TransactionService{Name: p.Name, Status: p.Status.String()})
+	}
+	return t
+}
+
+func fromTxState(state *tcc.TxState) *Transaction {
+	t := &Transaction{TxId: state.TxId, Status: state.Status.String(), Services: []TransactionService{}}
+	for _, ss := range state.Services {
+		status := tcc.ServiceNotStarted
+		switch {
+		case ss.CancelSucceeded:
+			status = tcc.ServiceCanceled
+		case ss.ConfirmSucceeded:
+			status = tcc.ServiceConfirmed
+		case ss.TrySucceeded:
+			status = tcc.ServiceTrySucceeded
+		}
+		t.Services = append(t.Services, TransactionService{Name: ss.Name, Status: status.String()})
+	}
+	return t
+}
+
+// decode decodes the JSON request body of at most maxRequestSize bytes into v,
+// or writes the error response and returns false.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil && len(data) == maxRequestSize {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body is larger than %d bytes", maxRequestSize))
+		return false
+	}
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tcc.ErrTxNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, tcc.ErrUnknownService):
+		writeError(w, http.StatusConflict, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,261 @@
+package tccserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dllen/g-tcc"
+	"github.com/dllen/g-tcc/tccredis"
+	"github.com/go-redis/redis/v8"
+)
+
+// leaseTTL is the lease of transactions in the leasing store.
+const leaseTTL = 50 * time.Millisecond
+
+// stores are stores the server is tested with, including one leasing transactions it loads.
+var stores = []struct {
+	name     string
+	newStore func(t *testing.T) tcc.Store
+}{
+	{name: "memory", newStore: func(t *testing.T) tcc.Store { return tcc.NewMemoryStore() }},
+	{name: "redis", newStore: func(t *testing.T) tcc.Store {
+		s, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("cannot run miniredis: %v", err)
+		}
+		t.Cleanup(s.Close)
+		return tccredis.NewStore(redis.NewClient(&redis.Options{Addr: s.Addr()}), tccredis.WithStoreTTL(leaseTTL))
+	}},
+}
+
+func do(t *testing.T, h http.Handler, method, path, body string, v interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, path, err, w.Body)
+		}
+	}
+	return w.Code
+}
+
+// waitFinished waits until the transaction started by s finishes.
+func waitFinished(t *testing.T, s *Server, txId string) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		_, ok := s.inflight[txId]
+		s.mu.Unlock()
+		if !ok {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("transaction %s does not finish", txId)
+}
+
+func TestServer(t *testing.T) {
+	for _, store := range stores {
+		t.Run(store.name, func(t *testing.T) {
+			testServer(t, store.newStore(t))
+		})
+	}
+}
+
+func testServer(t *testing.T, store tcc.Store) {
+	var mu sync.Mutex
+	failConfirm := true
+	var calls []string
+	participant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.URL.Path)
+		if r.URL.Path == "/b/confirm" && failConfirm {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer participant.Close()
+
+	s := NewServer(store, WithDirectorOptions(
+		tcc.WithMaxRetries(1), tcc.WithDecorrelatedJitter(time.Millisecond, time.Millisecond)))
+	defer s.Shutdown(context.Background())
+	for _, name := range []string{"b", "a"} {
+		body := `{"name":"` + name + `","try":"` + participant.URL + "/" + name + `/try","confirm":"` +
+			participant.URL + "/" + name + `/confirm","cancel":"` + participant.URL + "/" + name + `/cancel"}`
+		if code := do(t, s, http.MethodPut, "/participants", body, nil); code != http.StatusNoContent {
+			t.Fatalf("PUT /participants = %d", code)
+		}
+	}
+	var participants []Participant
+	do(t, s, http.MethodGet, "/participants", "", &participants)
+	if len(participants) != 2 || participants[0].Name != "a" {
+		t.Errorf("GET /participants = %+v, want a and b", participants)
+	}
+	if code := do(t, s, http.MethodPost, "/transactions", `{"participants":["a","c"]}`, nil); code != http.StatusBadRequest {
+		t.Errorf("POST /transactions with unknown participant = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := do(t, s, http.MethodPost, "/transactions", `{"participants":["a","b","a"]}`, nil); code != http.StatusBadRequest {
+		t.Errorf("POST /transactions with duplicated participant = %d, want %d", code, http.StatusBadRequest)
+	}
+	large := `{"participants":["a"],"padding":"` + strings.Repeat("x", maxRequestSize) + `"}`
+	if code := do(t, s, http.MethodPost, "/transactions", large, nil); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /transactions with large body = %d, want %d", code, http.StatusRequestEntityTooLarge)
+	}
+
+	var tx Transaction
+	if code := do(t, s, http.MethodPost, "/transactions", `{"participants":["a","b"]}`, &tx); code != http.StatusAccepted {
+		t.Fatalf("POST /transactions = %d", code)
+	}
+	waitFinished(t, s, tx.TxId)
+	do(t, s, http.MethodGet, "/transactions/"+tx.TxId, "", &tx)
+	want := []TransactionService{{Name: "a", Status: "confirmed"}, {Name: "b", Status: "try succeeded"}}
+	if tx.Status != "confirming" || !reflect.DeepEqual(tx.Services, want) {
+		t.Errorf("GET stuck transaction = %+v, want confirming with services %+v", tx, want)
+	}
+
+	mu.Lock()
+	failConfirm = false
+	calls = nil
+	mu.Unlock()
+	if code := do(t, s, http.MethodPost, "/transactions/"+tx.TxId+"/retry", "", &tx); code != http.StatusOK || tx.Status != "confirmed" {
+		t.Errorf("POST retry = %d, %+v, want confirmed", code, tx)
+	}
+	if !reflect.DeepEqual(calls, []string{"/b/confirm"}) {
+		t.Errorf("retry called %v, want only confirm of b", calls)
+	}
+	if code := do(t, s, http.MethodGet, "/transactions/"+tx.TxId, "", &tx); code != http.StatusOK || tx.Status != "confirmed" {
+		t.Errorf("GET finished transaction = %d, %+v, want confirmed", code, tx)
+	}
+	if code := do(t, s, http.MethodPost, "/transactions/"+tx.TxId+"/retry", "", nil); code != http.StatusNotFound {
+		t.Errorf("POST retry of finished transaction = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := do(t, s, http.MethodGet, "/transactions/unknown", "", nil); code != http.StatusNotFound {
+		t.Errorf("GET unknown transaction = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestServer_resolve(t *testing.T) {
+	ctx := context.Background()
+	store := tcc.NewMemoryStore()
+	err := store.SaveTxState(ctx, &tcc.TxState{TxId: "tx1", Status: tcc.StatusCanceling, Services: []tcc.ServiceState{
+		{Name: "s1", TrySucceeded: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(store)
+	defer s.Shutdown(ctx)
+	if code := do(t, s, http.MethodPost, "/transactions/tx1/resolve", `{"status":"failed"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("resolve as failed = %d, want %d", code, http.StatusBadRequest)
+	}
+	var tx Transaction
+	if code := do(t, s, http.MethodPost, "/transactions/tx1/resolve", `{"status":"canceled"}`, &tx); code != http.StatusOK || tx.Status != "canceled" {
+		t.Errorf("resolve = %d, %+v, want canceled", code, tx)
+	}
+	if states, _ := store.LoadPendingTx(ctx); len(states) != 0 {
+		t.Errorf("pending after resolve = %+v", states)
+	}
+}
+
+func TestServer_resolve_inflight(t *testing.T) {
+	release := make(chan struct{})
+	participant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/try" {
+			<-release
+		}
+	}))
+	defer participant.Close()
+	s := NewServer(tcc.NewMemoryStore())
+	defer s.Shutdown(context.Background())
+	body := `{"name":"p","try":"` + participant.URL + `/try","confirm":"` + participant.URL + `/confirm","cancel":"` + participant.URL + `/cancel"}`
+	do(t, s, http.MethodPut, "/participants", body, nil)
+	var tx Transaction
+	do(t, s, http.MethodPost, "/transactions", `{"participants":["p"]}`, &tx)
+	code := do(t, s, http.MethodPost, "/transactions/"+tx.TxId+"/resolve", `{"status":"canceled"}`, nil)
+	close(release)
+	if code != http.StatusConflict {
+		t.Errorf("resolve of transaction in flight = %d, want %d", code, http.StatusConflict)
+	}
+	waitFinished(t, s, tx.TxId)
+	do(t, s, http.MethodGet, "/transactions/"+tx.TxId, "", &tx)
+	if tx.Status != "confirmed" {
+		t.Errorf("transaction = %+v, want confirmed by its director", tx)
+	}
+}
+
+func TestServer_incidents(t *testing.T) {
+	for _, store := range stores {
+		t.Run(store.name, func(t *testing.T) {
+			testServerIncidents(t, store.newStore(t))
+		})
+	}
+}
+
+func testServerIncidents(t *testing.T, store tcc.Store) {
+	var mu sync.Mutex
+	fail := true
+	participant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/confirm" && fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer participant.Close()
+
+	var opened []tcc.Incident
+	s := NewServer(store,
+		WithIncidents(time.Minute, 1, func(i tcc.Incident) { opened = append(opened, i) }),
+		WithDirectorOptions(tcc.WithMaxRetries(1), tcc.WithDecorrelatedJitter(time.Millisecond, time.Millisecond)))
+	defer s.Shutdown(context.Background())
+	body := `{"name":"p","try":"` + participant.URL + `/try","confirm":"` + participant.URL + `/confirm","cancel":"` + participant.URL + `/cancel"}`
+	do(t, s, http.MethodPut, "/participants", body, nil)
+	var tx Transaction
+	do(t, s, http.MethodPost, "/transactions", `{"participants":["p"]}`, &tx)
+	waitFinished(t, s, tx.TxId)
+
+	var incidents []Incident
+	do(t, s, http.MethodGet, "/incidents", "", &incidents)
+	if len(incidents) != 1 || incidents[0].Service != "p" || !reflect.DeepEqual(incidents[0].TxIds, []string{tx.TxId}) {
+		t.Fatalf("GET /incidents = %+v, want an incident of p", incidents)
+	}
+	if len(opened) != 1 {
+		t.Errorf("onOpen called %d times, want 1", len(opened))
+	}
+	do(t, s, http.MethodGet, "/transactions/"+tx.TxId, "", &tx)
+	if !reflect.DeepEqual(tx.Incidents, []string{"p"}) {
+		t.Errorf("incidents of transaction = %v, want [p]", tx.Incidents)
+	}
+
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	// the lease taken by the failed transaction expires, so that the re-drive can claim it
+	time.Sleep(2 * leaseTTL)
+	if code := do(t, s, http.MethodPost, "/incidents/p/resolve", "", nil); code != http.StatusNoContent {
+		t.Fatalf("POST /incidents/p/resolve = %d", code)
+	}
+	var redriven Transaction
+	for i := 0; i < 100 && redriven.Status != "confirmed"; i++ {
+		time.Sleep(10 * time.Millisecond)
+		redriven = Transaction{}
+		do(t, s, http.MethodGet, "/transactions/"+tx.TxId, "", &redriven)
+	}
+	if redriven.Status != "confirmed" || redriven.Incidents != nil {
+		t.Errorf("transaction after resolving incident = %+v, want confirmed by re-drive", redriven)
+	}
+	if code := do(t, s, http.MethodPost, "/incidents/p/resolve", "", nil); code != http.StatusNotFound {
+		t.Errorf("resolving resolved incident = %d, want %d", code, http.StatusNotFound)
+	}
+}
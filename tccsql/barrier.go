@@ -0,0 +1,95 @@
+package tccsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dllen/g-tcc"
+)
+
+// Barrier protects phase functions of a participant from calls which TCC over unreliable networks makes:
+// cancel whose try never ran (empty compensation), try arriving after cancel (suspension),
+// and repeated confirm or cancel.
+// Each phase records a row in the participant's db within the local transaction of the phase,
+// so the record and the business change are committed or rolled back together.
+type Barrier struct {
+	s *store
+}
+
+// NewBarrier returns Barrier which records phases in db.
+// The table has to be created by MigrateBarrier or the statements returned by BarrierSchema.
+// Only WithTablePrefix of opts is used.
+func NewBarrier(db *sql.DB, dialect *Dialect, opts ...StoreOption) *Barrier {
+	return &Barrier{s: newStore(db, dialect, opts)}
+}
+
+// BarrierSchema returns statements creating the table used by Barrier with opts.
+func BarrierSchema(dialect *Dialect, opts ...StoreOption) []string {
+	s := newStore(nil, dialect, opts)
+	return []string{fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	tx_id VARCHAR(64) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	phase VARCHAR(16) NOT NULL,
+	PRIMARY KEY (tx_id, name, phase)
+)`, s.barrierTable)}
+}
+
+// MigrateBarrier creates the table used by Barrier with opts if it does not exist.
+func MigrateBarrier(ctx context.Context, db *sql.DB, dialect *Dialect, opts ...StoreOption) error {
+	for _, statement := range BarrierSchema(dialect, opts...) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migrate barrier %s: %w", dialect, err)
+		}
+	}
+	return nil
+}
+
+// Call calls f in a local transaction of db, unless the phase of the service in the transaction txId must be skipped:
+// try after cancel, cancel without try, and confirm or cancel which already succeeded are skipped and return nil.
+// If f returns error, the local transaction is rolled back with the record, so that the retried call runs f again.
+// In a phase function called by the director, txId, serviceName and phase can be taken from tcc.CallInfoFrom.
+func (b *Barrier) Call(ctx context.Context, txId, serviceName string, phase tcc.Phase, f func(tx *sql.Tx) error) error {
+	return b.s.inTx(ctx, func(tx *sql.Tx) error {
+		run, err := b.pass(ctx, tx, txId, serviceName, phase)
+		if err != nil || !run {
+			return err
+		}
+		return f(tx)
+	})
+}
+
+// pass records the phase, and reports if the phase function should run.
+func (b *Barrier) pass(ctx context.Context, tx *sql.Tx, txId, serviceName string, phase tcc.Phase) (bool, error) {
+	switch phase {
+	case tcc.PhaseTry, tcc.PhaseConfirm:
+		// try is not recorded yet unless cancel came first
+		return b.insert(ctx, tx, txId, serviceName, phase)
+	case tcc.PhaseCancel:
+		// recording try makes a try arriving later skip
+		tryMissing, err := b.insert(ctx, tx, txId, serviceName, tcc.PhaseTry)
+		if err != nil {
+			return false, err
+		}
+		first, err := b.insert(ctx, tx, txId, serviceName, tcc.PhaseCancel)
+		if err != nil {
+			return false, err
+		}
+		return first && !tryMissing, nil
+	default:
+		return false, fmt.Errorf("unknown phase: %s", phase)
+	}
+}
+
+// insert records the phase, and reports if it is not recorded yet.
+func (b *Barrier) insert(ctx context.Context, tx *sql.Tx, txId, serviceName string, phase tcc.Phase) (bool, error) {
+	res, err := tx.ExecContext(ctx, b.s.query(
+		b.s.dialect.insertIgnore+" INTO %s (tx_id, name, phase) VALUES (?, ?, ?)"+b.s.dialect.doNothing, b.s.barrierTable),
+		txId, serviceName, string(phase),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
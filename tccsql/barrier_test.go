@@ -0,0 +1,130 @@
+package tccsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dllen/g-tcc"
+)
+
+func TestBarrier_Call(t *testing.T) {
+	mysql := "INSERT IGNORE INTO tcc_barriers (tx_id, name, phase) VALUES (?, ?, ?)"
+	postgres := "INSERT INTO tcc_barriers (tx_id, name, phase) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING"
+	inserted, duplicate := sqlmock.NewResult(0, 1), sqlmock.NewResult(0, 0)
+	tests := []struct {
+		name    string
+		dialect *Dialect
+		phase   tcc.Phase
+		results []sql.Result
+		fErr    error
+		wantRun bool
+		wantErr bool
+	}{
+		{
+			name:    "try",
+			dialect: MySQL,
+			phase:   tcc.PhaseTry,
+			results: []sql.Result{inserted},
+			wantRun: true,
+		},
+		{
+			name:    "try after cancel",
+			dialect: MySQL,
+			phase:   tcc.PhaseTry,
+			results: []sql.Result{duplicate},
+		},
+		{
+			name:    "confirm",
+			dialect: Postgres,
+			phase:   tcc.PhaseConfirm,
+			results: []sql.Result{inserted},
+			wantRun: true,
+		},
+		{
+			name:    "repeated confirm",
+			dialect: Postgres,
+			phase:   tcc.PhaseConfirm,
+			results: []sql.Result{duplicate},
+		},
+		{
+			name:    "cancel",
+			dialect: MySQL,
+			phase:   tcc.PhaseCancel,
+			results: []sql.Result{duplicate, inserted},
+			wantRun: true,
+		},
+		{
+			name:    "cancel without try",
+			dialect: MySQL,
+			phase:   tcc.PhaseCancel,
+			results: []sql.Result{inserted, inserted},
+		},
+		{
+			name:    "repeated cancel",
+			dialect: MySQL,
+			phase:   tcc.PhaseCancel,
+			results: []sql.Result{duplicate, duplicate},
+		},
+		{
+			name:    "failed cancel is rolled back",
+			dialect: MySQL,
+			phase:   tcc.PhaseCancel,
+			results: []sql.Result{duplicate, inserted},
+			fErr:    errors.New("test"),
+			wantRun: true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatalf("cannot open sqlmock: %v", err)
+			}
+			defer db.Close()
+			insert := mysql
+			if tt.dialect == Postgres {
+				insert = postgres
+			}
+			phases := []tcc.Phase{tt.phase}
+			if tt.phase == tcc.PhaseCancel {
+				phases = []tcc.Phase{tcc.PhaseTry, tcc.PhaseCancel}
+			}
+			mock.ExpectBegin()
+			for i, phase := range phases {
+				mock.ExpectExec(insert).WithArgs("tx1", "s1", string(phase)).WillReturnResult(tt.results[i])
+			}
+			if tt.wantErr {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			run := false
+			err = NewBarrier(db, tt.dialect).Call(context.Background(), "tx1", "s1", tt.phase, func(tx *sql.Tx) error {
+				run = true
+				return tt.fErr
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Barrier.Call() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if run != tt.wantRun {
+				t.Errorf("Barrier.Call() ran f = %v, want %v", run, tt.wantRun)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBarrierSchema(t *testing.T) {
+	schema := strings.Join(BarrierSchema(MySQL, WithTablePrefix("app_")), ";\n")
+	if !strings.Contains(schema, "app_barriers") || !strings.Contains(schema, "PRIMARY KEY (tx_id, name, phase)") {
+		t.Errorf("BarrierSchema() = %s", schema)
+	}
+}
@@ -0,0 +1,72 @@
+package tccsql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect is SQL dialect of the database.
+type Dialect struct {
+	name string
+
+	// upsert is the clause appended to insert of a transaction to replace the saved one
+	upsert string
+
+	// insertIgnore and doNothing make insert which ignores a duplicate row
+	insertIgnore string
+	doNothing    string
+
+	// binary is the type of binary columns
+	binary string
+
+	// inlineIndex reports if indexes are created in CREATE TABLE,
+	// because CREATE INDEX IF NOT EXISTS is not supported
+	inlineIndex bool
+
+	// positional reports if bind variables are $1, $2, ... instead of ?
+	positional bool
+}
+
+var (
+	// MySQL is Dialect of MySQL 8.0 or later.
+	MySQL = &Dialect{
+		name:         "mysql",
+		upsert:       "ON DUPLICATE KEY UPDATE status = VALUES(status), lease_until = VALUES(lease_until)",
+		insertIgnore: "INSERT IGNORE",
+		binary:       "BLOB",
+		inlineIndex:  true,
+	}
+
+	// Postgres is Dialect of PostgreSQL 9.5 or later.
+	Postgres = &Dialect{
+		name:         "postgres",
+		upsert:       "ON CONFLICT (tx_id) DO UPDATE SET status = EXCLUDED.status, lease_until = EXCLUDED.lease_until",
+		insertIgnore: "INSERT",
+		doNothing:    " ON CONFLICT DO NOTHING",
+		binary:       "BYTEA",
+		positional:   true,
+	}
+)
+
+func (d *Dialect) String() string {
+	return d.name
+}
+
+// rebind replaces ? in query with bind variables of the dialect.
+func (d *Dialect) rebind(query string) string {
+	if !d.positional {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package tccsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Schema returns statements creating the tables used by Store with opts.
+// They can be copied to the migrations of another tool instead of calling Migrate.
+func Schema(dialect *Dialect, opts ...StoreOption) []string {
+	s := newStore(nil, dialect, opts)
+	index := ""
+	if dialect.inlineIndex {
+		index = fmt.Sprintf(",\n\tINDEX %s_pending (status, lease_until)", s.txTable)
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	tx_id VARCHAR(64) NOT NULL PRIMARY KEY,
+	status SMALLINT NOT NULL,
+	started_at BIGINT NOT NULL,
+	lease_until BIGINT NOT NULL%s
+)`, s.txTable, index),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	tx_id VARCHAR(64) NOT NULL,
+	seq INT NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	try_succeeded BOOLEAN NOT NULL,
+	confirm_succeeded BOOLEAN NOT NULL,
+	cancel_succeeded BOOLEAN NOT NULL,
+	try_result %s,
+	isolation VARCHAR(32) NOT NULL DEFAULT '',
+	PRIMARY KEY (tx_id, seq)
+)`, s.serviceTable, dialect.binary),
+	}
+	if !dialect.inlineIndex {
+		statements = append(statements, fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS %[1]s_pending ON %[1]s (status, lease_until)", s.txTable))
+	}
+	return statements
+}
+
+// Migrate creates the tables used by Store with opts if they do not exist.
+func Migrate(ctx context.Context, db *sql.DB, dialect *Dialect, opts ...StoreOption) error {
+	for _, statement := range Schema(dialect, opts...) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migrate %s: %w", dialect, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package tccsql
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect *Dialect
+		want    []string
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			want:    []string{"tcc_transactions", "INDEX tcc_transactions_pending", "tcc_services", "isolation VARCHAR(32)"},
+		},
+		{
+			name:    "postgres",
+			dialect: Postgres,
+			want:    []string{"tcc_transactions", "tcc_services", "CREATE INDEX IF NOT EXISTS tcc_transactions_pending"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := strings.Join(Schema(tt.dialect), ";\n")
+			last := 0
+			for _, want := range tt.want {
+				i := strings.Index(schema[last:], want)
+				if i < 0 {
+					t.Fatalf("Schema() = %s, want %q after offset %d", schema, want, last)
+				}
+				last += i + len(want)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("cannot open sqlmock: %v", err)
+	}
+	defer db.Close()
+	for _, statement := range Schema(Postgres) {
+		mock.ExpectExec(statement).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	if err := Migrate(context.Background(), db, Postgres); err != nil {
+		t.Errorf("Migrate() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
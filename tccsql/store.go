@@ -0,0 +1,420 @@
+// Package tccsql provides tcc.Store on top of database/sql for MySQL and PostgreSQL.
+package tccsql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dllen/g-tcc"
+)
+
+const (
+	defaultTablePrefix = "tcc_"
+	defaultLease       = time.Minute
+	defaultClaimLimit  = 100
+
+	// chunkSize is how many claimed transactions are leased and loaded by a query.
+	chunkSize = 500
+)
+
+// StoreOption can set option to Store
+type StoreOption func(s *store)
+
+// WithTablePrefix sets prefix of the table names, "tcc_" by default.
+// Store uses tables <prefix>transactions and <prefix>services, and Barrier uses <prefix>barriers.
+func WithTablePrefix(prefix string) StoreOption {
+	return func(s *store) {
+		s.txTable = prefix + "transactions"
+		s.serviceTable = prefix + "services"
+		s.barrierTable = prefix + "barriers"
+	}
+}
+
+// WithLease sets how long a transaction is owned by the coordinator which saved or loaded it last.
+// 1 minute by default. A pending transaction is not loaded by other coordinators until the lease expires,
+// so it should be longer than a transaction takes between saves, including retries of confirm and cancel.
+func WithLease(lease time.Duration) StoreOption {
+	return func(s *store) {
+		s.lease = lease
+	}
+}
+
+// WithClaimLimit sets how many transactions LoadPendingTx claims at most, 100 by default, and 0 means no limit.
+// Every claimed transaction is leased at once, and tcc.Recover recovers them one by one,
+// so the limit should be small enough for a batch to be recovered before the lease expires;
+// otherwise the rest of the batch is claimed again by other coordinators while it is still recovered.
+// The limit also leaves the rest of a large backlog to other coordinators;
+// call tcc.Recover again until it recovers nothing to recover the rest.
+func WithClaimLimit(limit int) StoreOption {
+	return func(s *store) {
+		s.claimLimit = limit
+	}
+}
+
+type store struct {
+	db           *sql.DB
+	dialect      *Dialect
+	txTable      string
+	serviceTable string
+	barrierTable string
+	lease        time.Duration
+	claimLimit   int
+	now          func() time.Time
+}
+
+// NewStore returns tcc.Store which saves transactions in db.
+// The tables have to be created by Migrate or the statements returned by Schema.
+//
+// Multiple coordinators can share the db. Saving a transaction leases it to the coordinator,
+// and LoadPendingTx only returns transactions whose lease expired, leasing them in turn.
+// Transactions are claimed with SELECT ... FOR UPDATE SKIP LOCKED,
+// so coordinators recovering at the same time never load the same transaction,
+// and a transaction is not confirmed twice at the same time.
+//
+// The store implements tcc.Getter, tcc.Claimer, tcc.Lister, tcc.StatusCounter and tcc.PendingInspector.
+func NewStore(db *sql.DB, dialect *Dialect, opts ...StoreOption) tcc.Store {
+	return newStore(db, dialect, opts)
+}
+
+func newStore(db *sql.DB, dialect *Dialect, opts []StoreOption) *store {
+	s := &store{
+		db:         db,
+		dialect:    dialect,
+		lease:      defaultLease,
+		claimLimit: defaultClaimLimit,
+		now:        time.Now,
+	}
+	WithTablePrefix(defaultTablePrefix)(s)
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *store) SaveTxState(ctx context.Context, state *tcc.TxState) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.query(
+			"INSERT INTO %s (tx_id, status, started_at, lease_until) VALUES (?, ?, ?, ?) "+s.dialect.upsert, s.txTable),
+			state.TxId, int(state.Status), millis(state.StartedAt), s.leaseUntil(),
+		); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, s.query(
+			"DELETE FROM %s WHERE tx_id = ?", s.serviceTable), state.TxId,
+		); err != nil {
+			return err
+		}
+		for i, ss := range state.Services {
+			if _, err := tx.ExecContext(ctx, s.query(
+				"INSERT INTO %s (tx_id, seq, name, try_succeeded, confirm_succeeded, cancel_succeeded, try_result, isolation) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", s.serviceTable),
+				state.TxId, i, ss.Name, ss.TrySucceeded, ss.ConfirmSucceeded, ss.CancelSucceeded, nullable(ss.TryResult), string(ss.Isolation),
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) MarkPhaseDone(ctx context.Context, txId, serviceName string, phase tcc.Phase) error {
+	var column string
+	switch phase {
+	case tcc.PhaseTry:
+		column = "try_succeeded"
+	case tcc.PhaseConfirm:
+		column = "confirm_succeeded"
+	case tcc.PhaseCancel:
+		column = "cancel_succeeded"
+	default:
+		return fmt.Errorf("unknown phase: %s", phase)
+	}
+	res, err := s.db.ExecContext(ctx, s.query(
+		"UPDATE %s SET "+column+" = ? WHERE tx_id = ? AND name = ?", s.serviceTable),
+		true, txId, serviceName,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	// MySQL reports no rows affected when the phase is already marked
+	var one int
+	err = s.db.QueryRowContext(ctx, s.query(
+		"SELECT 1 FROM %s WHERE tx_id = ? AND name = ?", s.serviceTable), txId, serviceName,
+	).Scan(&one)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: service %s", tcc.ErrTxNotFound, serviceName)
+	}
+	return err
+}
+
+func (s *store) LoadPendingTx(ctx context.Context) ([]*tcc.TxState, error) {
+	var states []*tcc.TxState
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		states, err = s.claimPending(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(states); i += chunkSize {
+			chunk := states[i:]
+			if len(chunk) > chunkSize {
+				chunk = chunk[:chunkSize]
+			}
+			if err := s.loadChunk(ctx, tx, chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// loadChunk leases the claimed transactions and loads their services, a query each.
+func (s *store) loadChunk(ctx context.Context, tx *sql.Tx, states []*tcc.TxState) error {
+	args := []interface{}{s.leaseUntil()}
+	for _, state := range states {
+		args = append(args, state.TxId)
+	}
+	if _, err := tx.ExecContext(ctx, s.query(
+		"UPDATE %s SET lease_until = ? WHERE tx_id IN ("+placeholders(len(states))+")", s.txTable), args...,
+	); err != nil {
+		return err
+	}
+	return s.loadServices(ctx, tx, states)
+}
+
+// querier is *sql.DB or *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadServices loads services of the transactions by a query.
+func (s *store) loadServices(ctx context.Context, q querier, states []*tcc.TxState) error {
+	ids := make([]interface{}, len(states))
+	byId := map[string]*tcc.TxState{}
+	for i, state := range states {
+		ids[i] = state.TxId
+		byId[state.TxId] = state
+	}
+	rows, err := q.QueryContext(ctx, s.query(
+		"SELECT tx_id, name, try_succeeded, confirm_succeeded, cancel_succeeded, try_result, isolation FROM %s WHERE tx_id IN ("+placeholders(len(ids))+") ORDER BY tx_id, seq", s.serviceTable),
+		ids...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var txId string
+		var ss tcc.ServiceState
+		if err := rows.Scan(&txId, &ss.Name, &ss.TrySucceeded, &ss.ConfirmSucceeded, &ss.CancelSucceeded, &ss.TryResult, &ss.Isolation); err != nil {
+			return err
+		}
+		if state, ok := byId[txId]; ok {
+			state.Services = append(state.Services, ss)
+		}
+	}
+	return rows.Err()
+}
+
+// claimPending locks pending transactions whose lease expired, skipping ones locked by other coordinators.
+func (s *store) claimPending(ctx context.Context, tx *sql.Tx) ([]*tcc.TxState, error) {
+	limit := ""
+	if s.claimLimit > 0 {
+		limit = fmt.Sprintf(" LIMIT %d", s.claimLimit)
+	}
+	rows, err := tx.QueryContext(ctx, s.query(
+		"SELECT tx_id, status, started_at FROM %s WHERE status IN (?, ?, ?) AND lease_until < ? ORDER BY tx_id"+limit+" FOR UPDATE SKIP LOCKED", s.txTable),
+		int(tcc.StatusTrying), int(tcc.StatusConfirming), int(tcc.StatusCanceling), millis(s.now()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTxStates(rows)
+}
+
+// scanTxStates scans tx_id, status and started_at of the rows, and closes them.
+func scanTxStates(rows *sql.Rows) ([]*tcc.TxState, error) {
+	defer rows.Close()
+	var states []*tcc.TxState
+	for rows.Next() {
+		var status int
+		var startedAt int64
+		state := &tcc.TxState{}
+		if err := rows.Scan(&state.TxId, &status, &startedAt); err != nil {
+			return nil, err
+		}
+		state.Status = tcc.Status(status)
+		state.StartedAt = fromMillis(startedAt)
+		states = append(states, state)
+	}
+	return states, rows.Err()
+}
+
+func (s *store) GetTx(ctx context.Context, txId string) (*tcc.TxState, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(
+		"SELECT tx_id, status, started_at FROM %s WHERE tx_id = ?", s.txTable), txId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	states, err := scanTxStates(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, tcc.ErrTxNotFound
+	}
+	if err := s.loadServices(ctx, s.db, states); err != nil {
+		return nil, err
+	}
+	return states[0], nil
+}
+
+func (s *store) ClaimTx(ctx context.Context, txId string) (*tcc.TxState, error) {
+	var state *tcc.TxState
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		// waits for a coordinator claiming it at the same time, unlike claimPending
+		rows, err := tx.QueryContext(ctx, s.query(
+			"SELECT tx_id, status, started_at FROM %s WHERE tx_id = ? AND status IN (?, ?, ?) FOR UPDATE", s.txTable),
+			txId, int(tcc.StatusTrying), int(tcc.StatusConfirming), int(tcc.StatusCanceling),
+		)
+		if err != nil {
+			return err
+		}
+		states, err := scanTxStates(rows)
+		if err != nil {
+			return err
+		}
+		if len(states) == 0 {
+			return tcc.ErrTxNotFound
+		}
+		state = states[0]
+		return s.loadChunk(ctx, tx, states)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *store) ListTx(ctx context.Context, after string, limit int) ([]*tcc.TxState, string, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(
+		"SELECT tx_id, status, started_at FROM %s WHERE tx_id > ? ORDER BY tx_id LIMIT "+strconv.Itoa(limit), s.txTable),
+		after,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	states, err := scanTxStates(rows)
+	if err != nil || len(states) == 0 {
+		return nil, "", err
+	}
+	if err := s.loadServices(ctx, s.db, states); err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(states) == limit {
+		next = states[len(states)-1].TxId
+	}
+	return states, next, nil
+}
+
+func (s *store) CountByStatus(ctx context.Context, since time.Time) (map[tcc.Status]int, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(
+		"SELECT status, COUNT(*) FROM %s WHERE started_at >= ? GROUP BY status", s.txTable),
+		millis(since),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := map[tcc.Status]int{}
+	for rows.Next() {
+		var status, count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[tcc.Status(status)] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *store) PendingStartTimes(ctx context.Context) ([]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, s.query(
+		"SELECT started_at FROM %s WHERE status IN (?, ?, ?)", s.txTable),
+		int(tcc.StatusTrying), int(tcc.StatusConfirming), int(tcc.StatusCanceling),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var startTimes []time.Time
+	for rows.Next() {
+		var startedAt int64
+		if err := rows.Scan(&startedAt); err != nil {
+			return nil, err
+		}
+		startTimes = append(startTimes, fromMillis(startedAt))
+	}
+	return startTimes, rows.Err()
+}
+
+func (s *store) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// query formats query with the table and rebinds it for the dialect.
+func (s *store) query(query, table string) string {
+	return s.dialect.rebind(fmt.Sprintf(query, table))
+}
+
+// leaseUntil returns unix time in milliseconds when the lease taken now expires.
+func (s *store) leaseUntil() int64 {
+	return millis(s.now().Add(s.lease))
+}
+
+// millis returns unix time of t in milliseconds, or 0 for zero t.
+func millis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// placeholders returns n placeholders separated by commas for IN.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// nullable returns nil for empty b, so that it is saved as NULL by every driver.
+func nullable(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return b
+}
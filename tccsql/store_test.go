@@ -0,0 +1,286 @@
+package tccsql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dllen/g-tcc"
+)
+
+var now = time.Unix(1000, 0)
+
+func newMock(t *testing.T, dialect *Dialect, opts ...StoreOption) (*store, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("cannot open sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+	s := newStore(db, dialect, opts)
+	s.now = func() time.Time { return now }
+	return s, mock
+}
+
+func Test_store_SaveTxState(t *testing.T) {
+	s, mock := newMock(t, Postgres, WithLease(time.Second))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tcc_transactions (tx_id, status, started_at, lease_until) VALUES ($1, $2, $3, $4) "+Postgres.upsert).
+		WithArgs("tx1", int(tcc.StatusConfirming), int64(900000), int64(1001000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM tcc_services WHERE tx_id = $1").
+		WithArgs("tx1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	insert := "INSERT INTO tcc_services (tx_id, seq, name, try_succeeded, confirm_succeeded, cancel_succeeded, try_result, isolation) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)"
+	mock.ExpectExec(insert).
+		WithArgs("tx1", 0, "s1", true, true, false, []byte("r1"), "lock").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(insert).
+		WithArgs("tx1", 1, "s2", true, false, false, nil, "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := s.SaveTxState(context.Background(), &tcc.TxState{
+		TxId:      "tx1",
+		Status:    tcc.StatusConfirming,
+		StartedAt: time.Unix(900, 0),
+		Services: []tcc.ServiceState{
+			{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true, TryResult: []byte("r1"), Isolation: tcc.IsolationLock},
+			{Name: "s2", TrySucceeded: true},
+		},
+	})
+	if err != nil {
+		t.Errorf("store.SaveTxState() error = %v", err)
+	}
+}
+
+func Test_store_SaveTxState_Rollback(t *testing.T) {
+	s, mock := newMock(t, MySQL)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO tcc_transactions (tx_id, status, started_at, lease_until) VALUES (?, ?, ?, ?) " + MySQL.upsert).
+		WillReturnError(errors.New("deadlock"))
+	mock.ExpectRollback()
+
+	if err := s.SaveTxState(context.Background(), &tcc.TxState{TxId: "tx1"}); err == nil {
+		t.Errorf("store.SaveTxState() error = nil, wantErr")
+	}
+}
+
+func Test_store_MarkPhaseDone(t *testing.T) {
+	update := "UPDATE tcc_services SET confirm_succeeded = ? WHERE tx_id = ? AND name = ?"
+	exists := "SELECT 1 FROM tcc_services WHERE tx_id = ? AND name = ?"
+	tests := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "marked",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).WithArgs(true, "tx1", "s1").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "already marked",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).WithArgs(true, "tx1", "s1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(exists).WithArgs("tx1", "s1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+			},
+		},
+		{
+			name: "not found",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(update).WithArgs(true, "tx1", "s1").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(exists).WithArgs("tx1", "s1").WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: tcc.ErrTxNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMock(t, MySQL)
+			tt.expect(mock)
+			err := s.MarkPhaseDone(context.Background(), "tx1", "s1", tcc.PhaseConfirm)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("store.MarkPhaseDone() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_store_LoadPendingTx(t *testing.T) {
+	s, mock := newMock(t, MySQL, WithTablePrefix("app_tcc_"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT tx_id, status, started_at FROM app_tcc_transactions WHERE status IN (?, ?, ?) AND lease_until < ? ORDER BY tx_id LIMIT 100 FOR UPDATE SKIP LOCKED").
+		WithArgs(int(tcc.StatusTrying), int(tcc.StatusConfirming), int(tcc.StatusCanceling), int64(1000000)).
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "status", "started_at"}).
+			AddRow("tx1", int(tcc.StatusConfirming), int64(900000)).
+			AddRow("tx2", int(tcc.StatusTrying), int64(0)))
+	mock.ExpectExec("UPDATE app_tcc_transactions SET lease_until = ? WHERE tx_id IN (?, ?)").
+		WithArgs(int64(1060000), "tx1", "tx2").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT tx_id, name, try_succeeded, confirm_succeeded, cancel_succeeded, try_result, isolation FROM app_tcc_services WHERE tx_id IN (?, ?) ORDER BY tx_id, seq").
+		WithArgs("tx1", "tx2").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "name", "try_succeeded", "confirm_succeeded", "cancel_succeeded", "try_result", "isolation"}).
+			AddRow("tx1", "s1", true, true, false, []byte("tx1"), "optimistic").
+			AddRow("tx2", "s1", true, false, false, []byte("tx2"), ""))
+	mock.ExpectCommit()
+
+	got, err := s.LoadPendingTx(context.Background())
+	if err != nil {
+		t.Fatalf("store.LoadPendingTx() error = %v", err)
+	}
+	want := []*tcc.TxState{
+		{TxId: "tx1", Status: tcc.StatusConfirming, StartedAt: time.Unix(900, 0), Services: []tcc.ServiceState{{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true, TryResult: []byte("tx1"), Isolation: tcc.IsolationOptimistic}}},
+		{TxId: "tx2", Status: tcc.StatusTrying, Services: []tcc.ServiceState{{Name: "s1", TrySucceeded: true, TryResult: []byte("tx2")}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("store.LoadPendingTx() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_store_LoadPendingTx_ClaimLimit(t *testing.T) {
+	s, mock := newMock(t, Postgres, WithClaimLimit(0))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT tx_id, status, started_at FROM tcc_transactions WHERE status IN ($1, $2, $3) AND lease_until < $4 ORDER BY tx_id FOR UPDATE SKIP LOCKED").
+		WithArgs(int(tcc.StatusTrying), int(tcc.StatusConfirming), int(tcc.StatusCanceling), int64(1000000)).
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "status", "started_at"}).AddRow("tx1", int(tcc.StatusCanceling), int64(0)))
+	mock.ExpectExec("UPDATE tcc_transactions SET lease_until = $1 WHERE tx_id IN ($2)").
+		WithArgs(int64(1060000), "tx1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT tx_id, name, try_succeeded, confirm_succeeded, cancel_succeeded, try_result, isolation FROM tcc_services WHERE tx_id IN ($1) ORDER BY tx_id, seq").
+		WithArgs("tx1").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "name", "try_succeeded", "confirm_succeeded", "cancel_succeeded", "try_result", "isolation"}).
+			AddRow("tx1", "s1", true, false, false, nil, ""))
+	mock.ExpectCommit()
+
+	got, err := s.LoadPendingTx(context.Background())
+	if err != nil {
+		t.Fatalf("store.LoadPendingTx() error = %v", err)
+	}
+	want := []*tcc.TxState{{TxId: "tx1", Status: tcc.StatusCanceling, Services: []tcc.ServiceState{{Name: "s1", TrySucceeded: true}}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("store.LoadPendingTx() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_store_GetTx(t *testing.T) {
+	s, mock := newMock(t, MySQL)
+	mock.ExpectQuery("SELECT tx_id, status, started_at FROM tcc_transactions WHERE tx_id = ?").
+		WithArgs("tx1").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "status", "started_at"}).AddRow("tx1", int(tcc.StatusConfirming), int64(0)))
+	mock.ExpectQuery("SELECT tx_id, name, try_succeeded, confirm_succeeded, cancel_succeeded, try_result, isolation FROM tcc_services WHERE tx_id IN (?) ORDER BY tx_id, seq").
+		WithArgs("tx1").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "name", "try_succeeded", "confirm_succeeded", "cancel_succeeded", "try_result", "isolation"}).
+			AddRow("tx1", "s1", true, false, false, nil, ""))
+	mock.ExpectQuery("SELECT tx_id, status, started_at FROM tcc_transactions WHERE tx_id = ?").
+		WithArgs("tx2").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "status", "started_at"}))
+
+	got, err := s.GetTx(context.Background(), "tx1")
+	want := &tcc.TxState{TxId: "tx1", Status: tcc.StatusConfirming, Services: []tcc.ServiceState{{Name: "s1", TrySucceeded: true}}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("store.GetTx() = %+v, %v, want %+v", got, err, want)
+	}
+	if _, err := s.GetTx(context.Background(), "tx2"); !errors.Is(err, tcc.ErrTxNotFound) {
+		t.Errorf("store.GetTx() of unknown transaction error = %v, want %v", err, tcc.ErrTxNotFound)
+	}
+}
+
+func Test_store_ClaimTx(t *testing.T) {
+	s, mock := newMock(t, Postgres)
+	claim := "SELECT tx_id, status, started_at FROM tcc_transactions WHERE tx_id = $1 AND status IN ($2, $3, $4) FOR UPDATE"
+	mock.ExpectBegin()
+	mock.ExpectQuery(claim).
+		WithArgs("tx1", int(tcc.StatusTrying), int(tcc.StatusConfirming), int(tcc.StatusCanceling)).
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "status", "started_at"}).AddRow("tx1", int(tcc.StatusCanceling), int64(0)))
+	mock.ExpectExec("UPDATE tcc_transactions SET lease_until = $1 WHERE tx_id IN ($2)").
+		WithArgs(int64(1060000), "tx1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT tx_id, name, try_succeeded, confirm_succeeded, cancel_succeeded, try_result, isolation FROM tcc_services WHERE tx_id IN ($1) ORDER BY tx_id, seq").
+		WithArgs("tx1").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "name", "try_succeeded", "confirm_succeeded", "cancel_succeeded", "try_result", "isolation"}).
+			AddRow("tx1", "s1", true, false, false, nil, ""))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(claim).
+		WithArgs("tx2", int(tcc.StatusTrying), int(tcc.StatusConfirming), int(tcc.StatusCanceling)).
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "status", "started_at"}))
+	mock.ExpectRollback()
+
+	got, err := s.ClaimTx(context.Background(), "tx1")
+	want := &tcc.TxState{TxId: "tx1", Status: tcc.StatusCanceling, Services: []tcc.ServiceState{{Name: "s1", TrySucceeded: true}}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("store.ClaimTx() = %+v, %v, want %+v", got, err, want)
+	}
+	if _, err := s.ClaimTx(context.Background(), "tx2"); !errors.Is(err, tcc.ErrTxNotFound) {
+		t.Errorf("store.ClaimTx() of finished transaction error = %v, want %v", err, tcc.ErrTxNotFound)
+	}
+}
+
+func Test_store_ListTx(t *testing.T) {
+	s, mock := newMock(t, MySQL)
+	mock.ExpectQuery("SELECT tx_id, status, started_at FROM tcc_transactions WHERE tx_id > ? ORDER BY tx_id LIMIT 2").
+		WithArgs("tx0").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "status", "started_at"}).
+			AddRow("tx1", int(tcc.StatusConfirmed), int64(0)).
+			AddRow("tx2", int(tcc.StatusFailed), int64(0)))
+	mock.ExpectQuery("SELECT tx_id, name, try_succeeded, confirm_succeeded, cancel_succeeded, try_result, isolation FROM tcc_services WHERE tx_id IN (?, ?) ORDER BY tx_id, seq").
+		WithArgs("tx1", "tx2").
+		WillReturnRows(sqlmock.NewRows([]string{"tx_id", "name", "try_succeeded", "confirm_succeeded", "cancel_succeeded", "try_result", "isolation"}).
+			AddRow("tx1", "s1", true, true, false, nil, "").
+			AddRow("tx2", "s1", true, false, false, nil, ""))
+
+	got, next, err := s.ListTx(context.Background(), "tx0", 2)
+	if err != nil {
+		t.Fatalf("store.ListTx() error = %v", err)
+	}
+	want := []*tcc.TxState{
+		{TxId: "tx1", Status: tcc.StatusConfirmed, Services: []tcc.ServiceState{{Name: "s1", TrySucceeded: true, ConfirmSucceeded: true}}},
+		{TxId: "tx2", Status: tcc.StatusFailed, Services: []tcc.ServiceState{{Name: "s1", TrySucceeded: true}}},
+	}
+	if !reflect.DeepEqual(got, want) || next != "tx2" {
+		t.Errorf("store.ListTx() = %+v, %q, want %+v, %q", got, next, want, "tx2")
+	}
+}
+
+func Test_store_CountByStatus(t *testing.T) {
+	s, mock := newMock(t, Postgres)
+	mock.ExpectQuery("SELECT status, COUNT(*) FROM tcc_transactions WHERE started_at >= $1 GROUP BY status").
+		WithArgs(int64(900000)).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).
+			AddRow(int(tcc.StatusConfirmed), 10).
+			AddRow(int(tcc.StatusFailed), 1))
+
+	got, err := s.CountByStatus(context.Background(), time.Unix(900, 0))
+	if err != nil {
+		t.Fatalf("store.CountByStatus() error = %v", err)
+	}
+	if want := map[tcc.Status]int{tcc.StatusConfirmed: 10, tcc.StatusFailed: 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("store.CountByStatus() = %v, want %v", got, want)
+	}
+}
+
+func Test_store_PendingStartTimes(t *testing.T) {
+	s, mock := newMock(t, MySQL)
+	mock.ExpectQuery("SELECT started_at FROM tcc_transactions WHERE status IN (?, ?, ?)").
+		WithArgs(int(tcc.StatusTrying), int(tcc.StatusConfirming), int(tcc.StatusCanceling)).
+		WillReturnRows(sqlmock.NewRows([]string{"started_at"}).AddRow(int64(900000)).AddRow(int64(950000)))
+
+	got, err := s.PendingStartTimes(context.Background())
+	if err != nil {
+		t.Fatalf("store.PendingStartTimes() error = %v", err)
+	}
+	want := []time.Time{time.Unix(900, 0), time.Unix(950, 0)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("store.PendingStartTimes() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+// Package tcctest provides helpers to test code using tcc.
+package tcctest
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/dllen/g-tcc"
+)
+
+// Script describes how a service behaves in a transaction run by CheckInvariants.
+type Script struct {
+	// TryFails makes try of the service fail.
+	TryFails bool
+
+	// ConfirmFailures is how many confirm calls fail before it succeeds.
+	ConfirmFailures int
+
+	// CancelFailures is how many cancel calls fail before it succeeds.
+	CancelFailures int
+}
+
+// ScriptsFromBytes decodes arbitrary bytes into scripts, 2 bytes per service,
+// so that CheckInvariants can be driven by go fuzzing or random generators.
+func ScriptsFromBytes(data []byte) []Script {
+	scripts := make([]Script, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		scripts = append(scripts, Script{
+			TryFails:        data[i]&1 == 1,
+			ConfirmFailures: int(data[i]>>1) % 4,
+			CancelFailures:  int(data[i+1]) % 4,
+		})
+	}
+	return scripts
+}
+
+// calls counts calls of a service.
+type calls struct {
+	try, confirm, cancel                int
+	trySucceeded, confirmSucceeded      bool
+	cancelSucceeded, confirmAfterCancel bool
+}
+
+// CheckInvariants runs a transaction of services behaving as scripts,
+// and reports to t every TCC invariant which does not hold:
+// try is called exactly once, confirm is never called after cancel or when some try failed,
+// cancel covers every successful try, and no service is left non-terminal without Direct reporting it.
+// By default failed confirm/cancel are not retried, pass tcc.WithMaxRetries to exercise retries.
+func CheckInvariants(t testing.TB, scripts []Script, opts ...tcc.Option) {
+	t.Helper()
+	var mu sync.Mutex
+	got := make([]*calls, len(scripts))
+	services := make([]*tcc.Service, len(scripts))
+	for i, script := range scripts {
+		i, script, c := i, script, &calls{}
+		got[i] = c
+		services[i] = tcc.NewService(
+			fmt.Sprintf("s%d", i),
+			func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				c.try++
+				if script.TryFails {
+					return errors.New("try failed by script")
+				}
+				c.trySucceeded = true
+				return nil
+			},
+			func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				c.confirm++
+				if c.cancel > 0 {
+					c.confirmAfterCancel = true
+				}
+				if c.confirm <= script.ConfirmFailures {
+					return errors.New("confirm failed by script")
+				}
+				c.confirmSucceeded = true
+				return nil
+			},
+			func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				c.cancel++
+				if c.cancel <= script.CancelFailures {
+					return errors.New("cancel failed by script")
+				}
+				c.cancelSucceeded = true
+				return nil
+			},
+		)
+	}
+	err := tcc.NewDirector(services, append([]tcc.Option{tcc.WithMaxRetries(0)}, opts...)...).Direct()
+	for _, violation := range check(got, err) {
+		t.Errorf("%v (scripts: %+v)", violation, scripts)
+	}
+}
+
+// check returns violated invariants of a transaction which made calls and returned err.
+func check(got []*calls, err error) []string {
+	var violations []string
+	allTried := true
+	for _, c := range got {
+		if !c.trySucceeded {
+			allTried = false
+		}
+	}
+	nonTerminal := false
+	for i, c := range got {
+		if c.try != 1 {
+			violations = append(violations, fmt.Sprintf("s%d: try called %d times, want 1", i, c.try))
+		}
+		if c.confirmAfterCancel {
+			violations = append(violations, fmt.Sprintf("s%d: confirm called after cancel", i))
+		}
+		if !allTried && c.confirm > 0 {
+			violations = append(violations, fmt.Sprintf("s%d: confirm called although some try failed", i))
+		}
+		if allTried && c.cancel > 0 {
+			violations = append(violations, fmt.Sprintf("s%d: cancel called although every try succeeded", i))
+		}
+		if !allTried && c.trySucceeded && c.cancel == 0 {
+			violations = append(violations, fmt.Sprintf("s%d: try succeeded but cancel never called", i))
+		}
+		if c.trySucceeded && !c.confirmSucceeded && !c.cancelSucceeded {
+			nonTerminal = true
+		}
+	}
+	if allTried && !nonTerminal && err != nil {
+		violations = append(violations, fmt.Sprintf("Direct() returned %v although every service is confirmed", err))
+	}
+	if !allTried && err == nil {
+		violations = append(violations, "Direct() returned nil although some try failed")
+	}
+	if nonTerminal {
+		var e *tcc.Error
+		if !errors.As(err, &e) || e.FailedPhase() == tcc.ErrTryFailed {
+			violations = append(violations, fmt.Sprintf("some service is left non-terminal but Direct() returned %v", err))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,101 @@
+package tcctest
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/dllen/g-tcc"
+)
+
+func TestCheckInvariants(t *testing.T) {
+	tests := []struct {
+		name    string
+		scripts []Script
+	}{
+		{
+			name:    "no service",
+			scripts: nil,
+		},
+		{
+			name:    "every phase succeeds",
+			scripts: []Script{{}, {}},
+		},
+		{
+			name:    "try fails",
+			scripts: []Script{{}, {TryFails: true}},
+		},
+		{
+			name:    "confirm fails",
+			scripts: []Script{{ConfirmFailures: 1}, {}},
+		},
+		{
+			name:    "cancel fails",
+			scripts: []Script{{CancelFailures: 1}, {TryFails: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CheckInvariants(t, tt.scripts)
+		})
+	}
+}
+
+func TestCheckInvariants_Random(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		data := make([]byte, r.Intn(10))
+		r.Read(data)
+		CheckInvariants(t, ScriptsFromBytes(data), tcc.WithDeterministicSchedule(r.Int63()))
+	}
+}
+
+func Test_check(t *testing.T) {
+	tests := []struct {
+		name           string
+		calls          []*calls
+		err            error
+		wantViolations int
+	}{
+		{
+			name:           "confirmed",
+			calls:          []*calls{{try: 1, trySucceeded: true, confirm: 1, confirmSucceeded: true}},
+			wantViolations: 0,
+		},
+		{
+			name:           "try retried",
+			calls:          []*calls{{try: 2, trySucceeded: true, confirm: 1, confirmSucceeded: true}},
+			wantViolations: 1,
+		},
+		{
+			name: "confirm after failed try",
+			calls: []*calls{
+				{try: 1, trySucceeded: true, confirm: 1, confirmSucceeded: true},
+				{try: 1},
+			},
+			err:            errors.New("test"),
+			wantViolations: 2,
+		},
+		{
+			name: "successful try not canceled",
+			calls: []*calls{
+				{try: 1, trySucceeded: true},
+				{try: 1},
+			},
+			err:            errors.New("test"),
+			wantViolations: 2,
+		},
+		{
+			name:           "confirm failure not reported",
+			calls:          []*calls{{try: 1, trySucceeded: true, confirm: 1}},
+			wantViolations: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := check(tt.calls, tt.err); len(got) != tt.wantViolations {
+				t.Errorf("check() = %v, want %d violations", got, tt.wantViolations)
+			}
+		})
+	}
+}
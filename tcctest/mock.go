@@ -0,0 +1,185 @@
+package tcctest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dllen/g-tcc"
+)
+
+// MockDirector is tcc.Director whose behaviour is set by DirectFunc, DirectContextFunc and DirectResultFunc.
+// Its methods can be called concurrently, e.g. from transactions directed by workers.
+type MockDirector struct {
+	DirectFunc        func() error
+	DirectContextFunc func(ctx context.Context) error
+	DirectResultFunc  func(ctx context.Context) *tcc.Result
+
+	// TxIdReturns is returned by TxId.
+	TxIdReturns string
+
+	// StateReturns is returned by State.
+	StateReturns *tcc.State
+
+	// DirectCalls is how many times Direct, DirectContext or DirectResult is called.
+	// Read it once the calls are finished, or use DirectCallCount while methods may still be called.
+	DirectCalls int
+
+	mu sync.Mutex
+}
+
+func (m *MockDirector) called() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.DirectCalls++
+}
+
+// DirectCallCount returns how many times Direct, DirectContext or DirectResult is called.
+func (m *MockDirector) DirectCallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.DirectCalls
+}
+
+// Direct calls DirectFunc, or returns nil if it is not set.
+func (m *MockDirector) Direct() error {
+	m.called()
+	if m.DirectFunc == nil {
+		return nil
+	}
+	return m.DirectFunc()
+}
+
+// DirectContext calls DirectContextFunc, or DirectFunc if it is not set.
+func (m *MockDirector) DirectContext(ctx context.Context) error {
+	if m.DirectContextFunc == nil {
+		return m.Direct()
+	}
+	m.called()
+	return m.DirectContextFunc(ctx)
+}
+
+// DirectResult calls DirectResultFunc, or returns Result with the error of DirectContext if it is not set.
+func (m *MockDirector) DirectResult(ctx context.Context) *tcc.Result {
+	if m.DirectResultFunc == nil {
+		return &tcc.Result{Err: m.DirectContext(ctx)}
+	}
+	m.called()
+	return m.DirectResultFunc(ctx)
+}
+
+// DirectAsync sends the result of DirectResult to the returned channel.
+func (m *MockDirector) DirectAsync(ctx context.Context) <-chan *tcc.Result {
+	results := make(chan *tcc.Result, 1)
+	results <- m.DirectResult(ctx)
+	close(results)
+	return results
+}
+
+// TxId returns TxIdReturns.
+func (m *MockDirector) TxId() string {
+	return m.TxIdReturns
+}
+
+// State returns StateReturns.
+func (m *MockDirector) State() *tcc.State {
+	return m.StateReturns
+}
+
+// MockTransaction is tcc.Transaction whose behaviour is set by its functions.
+// Methods of which function is not set do nothing and return zero values,
+// except Status, TxId and State which return StatusReturns, TxIdReturns and StateReturns.
+// Its methods can be called concurrently, e.g. Abort while Wait is blocking.
+type MockTransaction struct {
+	StartFunc  func()
+	WaitFunc   func() error
+	AbortFunc  func()
+	ResumeFunc func() error
+
+	// StatusReturns is returned by Status.
+	StatusReturns tcc.Status
+
+	// TxIdReturns is returned by TxId.
+	TxIdReturns string
+
+	// StateReturns is returned by State.
+	StateReturns *tcc.State
+
+	// Calls counts calls by method name.
+	// Read it once the calls are finished, or use CallCount while methods may still be called.
+	Calls map[string]int
+
+	mu sync.Mutex
+}
+
+func (m *MockTransaction) called(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Calls == nil {
+		m.Calls = map[string]int{}
+	}
+	m.Calls[method]++
+}
+
+// CallCount returns how many times the method is called.
+func (m *MockTransaction) CallCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.Calls[method]
+}
+
+// Start calls StartFunc.
+func (m *MockTransaction) Start() {
+	m.called("Start")
+	if m.StartFunc != nil {
+		m.StartFunc()
+	}
+}
+
+// Wait calls WaitFunc.
+func (m *MockTransaction) Wait() error {
+	m.called("Wait")
+	if m.WaitFunc == nil {
+		return nil
+	}
+	return m.WaitFunc()
+}
+
+// Abort calls AbortFunc.
+func (m *MockTransaction) Abort() {
+	m.called("Abort")
+	if m.AbortFunc != nil {
+		m.AbortFunc()
+	}
+}
+
+// Status returns StatusReturns.
+func (m *MockTransaction) Status() tcc.Status {
+	m.called("Status")
+	return m.StatusReturns
+}
+
+// TxId returns TxIdReturns.
+func (m *MockTransaction) TxId() string {
+	m.called("TxId")
+	return m.TxIdReturns
+}
+
+// State returns StateReturns.
+func (m *MockTransaction) State() *tcc.State {
+	m.called("State")
+	return m.StateReturns
+}
+
+// Resume calls ResumeFunc.
+func (m *MockTransaction) Resume() error {
+	m.called("Resume")
+	if m.ResumeFunc == nil {
+		return nil
+	}
+	return m.ResumeFunc()
+}
+
+var (
+	_ tcc.Director    = (*MockDirector)(nil)
+	_ tcc.Transaction = (*MockTransaction)(nil)
+)
@@ -0,0 +1,93 @@
+package tcctest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/dllen/g-tcc"
+)
+
+func TestMockDirector_Direct(t *testing.T) {
+	want := errors.New("test")
+	m := &MockDirector{DirectFunc: func() error { return want }}
+	var d tcc.Director = m
+	if err := d.Direct(); err != want {
+		t.Errorf("MockDirector.Direct() error = %v, want %v", err, want)
+	}
+	if m.DirectCalls != 1 {
+		t.Errorf("MockDirector.DirectCalls = %v, want 1", m.DirectCalls)
+	}
+}
+
+func TestMockTransaction(t *testing.T) {
+	var aborted bool
+	m := &MockTransaction{
+		AbortFunc:     func() { aborted = true },
+		StatusReturns: tcc.StatusCanceled,
+	}
+	var tx tcc.Transaction = m
+	tx.Start()
+	tx.Abort()
+	if err := tx.Wait(); err != nil {
+		t.Errorf("MockTransaction.Wait() error = %v", err)
+	}
+	if got := tx.Status(); got != tcc.StatusCanceled {
+		t.Errorf("MockTransaction.Status() = %v, want %v", got, tcc.StatusCanceled)
+	}
+	if !aborted {
+		t.Errorf("AbortFunc is not called")
+	}
+	for _, method := range []string{"Start", "Abort", "Wait", "Status"} {
+		if m.Calls[method] != 1 {
+			t.Errorf("%v called %v times, want 1", method, m.Calls[method])
+		}
+	}
+}
+
+func TestMockTransaction_concurrent(t *testing.T) {
+	release := make(chan struct{})
+	m := &MockTransaction{
+		WaitFunc:  func() error { <-release; return nil },
+		AbortFunc: func() { close(release) },
+	}
+	done := make(chan struct{})
+	go func() {
+		_ = m.Wait()
+		close(done)
+	}()
+	m.Abort()
+	<-done
+	if m.CallCount("Wait") != 1 || m.CallCount("Abort") != 1 {
+		t.Errorf("Calls = %v, want Wait and Abort once", m.Calls)
+	}
+}
+
+func TestMockDirector_DirectResult(t *testing.T) {
+	want := &tcc.Result{Status: tcc.StatusCanceled}
+	m := &MockDirector{DirectResultFunc: func(context.Context) *tcc.Result { return want }}
+	if got := m.DirectResult(context.Background()); got != want {
+		t.Errorf("MockDirector.DirectResult() = %v, want %v", got, want)
+	}
+	if m.DirectCalls != 1 {
+		t.Errorf("MockDirector.DirectCalls = %v, want 1", m.DirectCalls)
+	}
+}
+
+func TestMockDirector_concurrent(t *testing.T) {
+	m := &MockDirector{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = m.DirectContext(context.Background())
+			_ = m.DirectCallCount()
+		}()
+	}
+	wg.Wait()
+	if got := m.DirectCallCount(); got != 8 {
+		t.Errorf("MockDirector.DirectCallCount() = %v, want 8", got)
+	}
+}
@@ -0,0 +1,109 @@
+package tcctest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dllen/g-tcc"
+)
+
+// UpdateGoldenEnv is the environment variable which makes AssertTrace
+// overwrite golden files with recorded traces instead of comparing them.
+const UpdateGoldenEnv = "TCCTEST_UPDATE_GOLDEN"
+
+// Event is a phase call recorded by Recorder.
+type Event struct {
+	Service string
+	Phase   string
+	Err     error
+}
+
+func (e Event) String() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s %s: %v", e.Service, e.Phase, e.Err)
+	}
+	return fmt.Sprintf("%s %s: ok", e.Service, e.Phase)
+}
+
+// Recorder records phase calls of the services it made, in the order they returned.
+// Use it with tcc.WithDeterministicSchedule to get the same trace on every run.
+type Recorder struct {
+	events []Event
+
+	sync.Mutex
+}
+
+// NewRecorder returns empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Service returns tcc.Service which records calls of try, confirm and cancel.
+// Nil functions succeed.
+func (r *Recorder) Service(name string, try, confirm, cancel func() error, opts ...tcc.ServiceOption) *tcc.Service {
+	return tcc.NewService(
+		name,
+		r.record(name, "try", try),
+		r.record(name, "confirm", confirm),
+		r.record(name, "cancel", cancel),
+		opts...,
+	)
+}
+
+func (r *Recorder) record(service, phase string, f func() error) func() error {
+	return func() error {
+		var err error
+		if f != nil {
+			err = f()
+		}
+		r.Lock()
+		defer r.Unlock()
+		r.events = append(r.events, Event{Service: service, Phase: phase, Err: err})
+		return err
+	}
+}
+
+// Events returns recorded events.
+func (r *Recorder) Events() []Event {
+	r.Lock()
+	defer r.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// String returns recorded trace, one event per line.
+func (r *Recorder) String() string {
+	var b strings.Builder
+	for _, e := range r.Events() {
+		b.WriteString(e.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// AssertTrace reports to t if the trace recorded by r differs from the golden file.
+// If the environment variable TCCTEST_UPDATE_GOLDEN is set, the golden file is overwritten instead.
+func AssertTrace(t testing.TB, r *Recorder, golden string) {
+	t.Helper()
+	got := r.String()
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(golden), 0755); err != nil {
+			t.Fatalf("cannot create directory of golden file: %v", err)
+		}
+		if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatalf("cannot update golden file: %v", err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("cannot read golden file: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("trace differs from %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package tcctest
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/dllen/g-tcc"
+)
+
+func TestAssertTrace(t *testing.T) {
+	tests := []struct {
+		name   string
+		tryErr error
+		golden string
+	}{
+		{
+			name:   "confirmed",
+			golden: "testdata/confirmed.golden",
+		},
+		{
+			name:   "canceled",
+			tryErr: errors.New("out of stock"),
+			golden: "testdata/canceled.golden",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRecorder()
+			services := []*tcc.Service{
+				r.Service("storage", nil, nil, nil),
+				r.Service("coupon", func() error { return tt.tryErr }, nil, nil),
+				r.Service("payment", nil, nil, nil),
+			}
+			_ = tcc.NewDirector(services, tcc.WithDeterministicSchedule(1)).Direct()
+			AssertTrace(t, r, tt.golden)
+		})
+	}
+}
+
+type spyT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *spyT) Helper() {}
+
+func (t *spyT) Errorf(format string, args ...interface{}) { t.failed = true }
+
+func TestAssertTrace_Differs(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "trace.golden")
+	if err := ioutil.WriteFile(golden, []byte("s1 try: ok\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder()
+	_ = tcc.NewDirector([]*tcc.Service{r.Service("s1", nil, nil, nil)}).Direct()
+	spy := &spyT{TB: t}
+	AssertTrace(spy, r, golden)
+	if !spy.failed {
+		t.Errorf("AssertTrace() does not report different trace")
+	}
+}
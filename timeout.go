@@ -0,0 +1,61 @@
+package tcc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WithTryTimeout limits how long each try of the service takes.
+// See WithConfirmTimeout for how the timeout works.
+func WithTryTimeout(timeout time.Duration) ServiceOption {
+	return withTimeout(PhaseTry, timeout)
+}
+
+// WithConfirmTimeout limits how long each confirm call of the service takes.
+// The timeout applies to every attempt separately, so retries get a fresh deadline,
+// and the whole phase takes up to the timeout times the attempts plus the backoff between them.
+// The call receives context with the deadline, and fails with context.DeadlineExceeded
+// if it returns after the deadline, even if it returned nil,
+// so that a slow participant fails fast instead of stalling the whole transaction.
+// A try which returned nil after the deadline may have reserved, so it is canceled like a succeeded one.
+// Phase functions need to honor the context to stop in time.
+func WithConfirmTimeout(timeout time.Duration) ServiceOption {
+	return withTimeout(PhaseConfirm, timeout)
+}
+
+// WithCancelTimeout limits how long each cancel call of the service takes, per attempt like WithConfirmTimeout.
+// See WithConfirmTimeout for how the timeout works.
+func WithCancelTimeout(timeout time.Duration) ServiceOption {
+	return withTimeout(PhaseCancel, timeout)
+}
+
+func withTimeout(phase Phase, timeout time.Duration) ServiceOption {
+	return func(s *Service) {
+		if s.timeouts == nil {
+			s.timeouts = map[Phase]time.Duration{}
+		}
+		s.timeouts[phase] = timeout
+	}
+}
+
+// callWithTimeout calls f of the service in the phase with the timeout of the phase, if it is set.
+// The timeout starts after waiting for the limiter of the service.
+func (s *Service) callWithTimeout(ctx context.Context, phase Phase, f PhaseFunc) error {
+	timeout := s.timeouts[phase]
+	if timeout <= 0 {
+		return s.call(ctx, f)
+	}
+	return s.call(ctx, func(parent context.Context) error {
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		defer cancel()
+		err := f(ctx)
+		if ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+			if err == nil && phase == PhaseTry {
+				s.lateTry = true
+			}
+			return fmt.Errorf("%s of %s timed out after %v: %w", phase, s.name, timeout, context.DeadlineExceeded)
+		}
+		return err
+	})
+}
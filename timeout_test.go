@@ -0,0 +1,87 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithTryTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		try          PhaseFunc
+		wantErr      error
+		wantCanceled bool
+	}{
+		{
+			name:    "in time",
+			try:     func(ctx context.Context) error { return nil },
+			wantErr: nil,
+		},
+		{
+			name: "honoring context",
+			try: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "ignoring context",
+			try: func(ctx context.Context) error {
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			},
+			wantErr: context.DeadlineExceeded,
+			// the late try may have reserved
+			wantCanceled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var canceled bool
+			d := NewDirector([]*Service{
+				NewServiceContext(
+					"s1",
+					tt.try,
+					func(ctx context.Context) error { return nil },
+					func(ctx context.Context) error { canceled = true; return nil },
+					WithTryTimeout(10*time.Millisecond),
+				),
+			})
+			err := d.Direct()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("director.Direct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if canceled != tt.wantCanceled {
+				t.Errorf("canceled = %v, want %v", canceled, tt.wantCanceled)
+			}
+		})
+	}
+}
+
+func TestWithConfirmTimeout(t *testing.T) {
+	attempts := 0
+	d := NewDirector([]*Service{
+		NewServiceContext(
+			"s1",
+			func(ctx context.Context) error { return nil },
+			func(ctx context.Context) error {
+				attempts++
+				if attempts == 1 {
+					<-ctx.Done()
+				}
+				return nil
+			},
+			func(ctx context.Context) error { return nil },
+			WithConfirmTimeout(10*time.Millisecond),
+		),
+	}, WithDecorrelatedJitter(time.Millisecond, time.Millisecond))
+	if err := d.Direct(); err != nil {
+		t.Errorf("director.Direct() error = %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("confirm attempts = %d, want 2", attempts)
+	}
+}
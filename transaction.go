@@ -0,0 +1,247 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var (
+	// ErrAborted is returned when the transaction is canceled by Abort.
+	ErrAborted = errors.New("transaction is aborted")
+
+	// ErrNotResumable is returned by Resume when the transaction is not failed.
+	ErrNotResumable = errors.New("transaction is not failed")
+)
+
+// Status is the status of a transaction.
+type Status int
+
+const (
+	// StatusNotStarted means the transaction is not started yet.
+	StatusNotStarted Status = iota
+
+	// StatusTrying means try phase is in progress.
+	StatusTrying
+
+	// StatusConfirming means confirm phase is in progress.
+	StatusConfirming
+
+	// StatusCanceling means cancel phase is in progress.
+	StatusCanceling
+
+	// StatusConfirmed means every service is confirmed.
+	StatusConfirmed
+
+	// StatusCanceled means every service which succeeded to try is canceled.
+	StatusCanceled
+
+	// StatusFailed means confirm or cancel of some services never succeeded after retries.
+	// The transaction can be resumed by Resume, or needs to be fixed manually.
+	StatusFailed
+)
+
+// pending returns if the transaction with the status is not finished.
+func (s Status) pending() bool {
+	return s == StatusTrying || s == StatusConfirming || s == StatusCanceling
+}
+
+func (s Status) String() string {
+	switch s {
+	case StatusNotStarted:
+		return "not started"
+	case StatusTrying:
+		return "trying"
+	case StatusConfirming:
+		return "confirming"
+	case StatusCanceling:
+		return "canceling"
+	case StatusConfirmed:
+		return "confirmed"
+	case StatusCanceled:
+		return "canceled"
+	case StatusFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+}
+
+// Transaction is a TCC transaction directed in background,
+// which can be observed and controlled while it is in progress.
+type Transaction interface {
+	// Start starts directing the transaction in background.
+	// It has no effect if the transaction is already started.
+	Start()
+
+	// Wait starts the transaction if it is not started, waits until it finishes,
+	// and returns the same error as Director.Direct.
+	Wait() error
+
+	// Abort makes the transaction cancel instead of confirm,
+	// and cancels context passed to validate and try functions in progress.
+	// It has no effect once confirm phase started.
+	Abort()
+
+	// Status returns the current status of the transaction.
+	Status() Status
+
+	// TxId returns ID of the transaction.
+	TxId() string
+
+	// State returns the current status of the transaction and the progress of every service.
+	State() *State
+
+	// Resume retries confirm or cancel of the services which never succeeded,
+	// when the transaction finished with StatusFailed.
+	Resume() error
+}
+
+// NewTransaction returns Transaction of the services, which is not started yet.
+func NewTransaction(services []*Service, opts ...Option) Transaction {
+	return newDirector(services, opts...)
+}
+
+func (d *director) Start() {
+	d.start.Do(func() {
+		go func() {
+			d.err = d.DirectContext(context.Background())
+			close(d.done)
+		}()
+	})
+}
+
+func (d *director) Wait() error {
+	d.Start()
+	<-d.done
+	return d.err
+}
+
+func (d *director) Abort() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.confirmStarted {
+		return
+	}
+	d.aborted = true
+	if d.cancelTry != nil {
+		d.cancelTry()
+	}
+}
+
+func (d *director) Status() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.status
+}
+
+func (d *director) Resume() error {
+	return d.resume(context.Background())
+}
+
+func (d *director) resume(ctx context.Context) error {
+	d.mu.Lock()
+	if d.status != StatusFailed {
+		d.mu.Unlock()
+		return ErrNotResumable
+	}
+	confirming := d.confirmStarted
+	if confirming {
+		d.status = StatusConfirming
+	} else {
+		d.status = StatusCanceling
+	}
+	d.mu.Unlock()
+	if confirming {
+		d.emit(StatusConfirming)
+	} else {
+		d.emit(StatusCanceling)
+	}
+	// resuming is the manual recovery, which gets a new limit of calls
+	atomic.StoreInt64(&d.calls, 0)
+	return d.measure(func() error { return d.finish(ctx, confirming) })
+}
+
+// finish confirms or cancels the services which never succeeded,
+// and releases the resources of the transaction once it does.
+func (d *director) finish(ctx context.Context, confirming bool) error {
+	if !confirming {
+		err := d.cancelAll(ctx)
+		if err != nil {
+			d.setStatus(StatusFailed)
+			return err
+		}
+		d.setStatus(StatusCanceled)
+		d.unlockResources()
+		if err := d.save(ctx, StatusCanceled); err != nil {
+			return &CoordinatorError{op: "save transaction state", err: err}
+		}
+		return nil
+	}
+	if err := d.confirmAll(ctx); err != nil {
+		d.setStatus(StatusFailed)
+		return err
+	}
+	d.setStatus(StatusConfirmed)
+	d.unlockResources()
+	if err := d.save(ctx, StatusConfirmed); err != nil {
+		return &CoordinatorError{op: "save transaction state", err: err}
+	}
+	d.complete()
+	return nil
+}
+
+// begin moves the transaction to try phase, unless it is aborted or ctx is done.
+// cancelTry is called when the transaction is aborted in try phase.
+func (d *director) begin(ctx context.Context, cancelTry context.CancelFunc) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := ctx.Err()
+	if d.aborted {
+		err = ErrAborted
+	}
+	if err != nil {
+		return &Error{
+			failedPhase: ErrTryFailed,
+			err:         err,
+		}
+	}
+	d.status = StatusTrying
+	d.cancelTry = cancelTry
+	return nil
+}
+
+// enterConfirm moves the transaction to confirm phase if try phase succeeded and it is not aborted,
+// otherwise returns the error to cancel the transaction with.
+// The decision to confirm is saved before confirm phase, if it cannot be saved the transaction is canceled.
+func (d *director) enterConfirm(ctx context.Context, tryErr error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.aborted {
+		return &Error{
+			failedPhase: ErrTryFailed,
+			err:         ErrAborted,
+		}
+	}
+	if tryErr != nil {
+		return tryErr
+	}
+	if err := d.save(ctx, StatusConfirming); err != nil {
+		return &Error{
+			failedPhase: ErrTryFailed,
+			err:         &CoordinatorError{op: "save transaction state", err: err},
+		}
+	}
+	d.status = StatusConfirming
+	d.confirmStarted = true
+	return nil
+}
+
+// setStatus sets the status and emits its event.
+func (d *director) setStatus(status Status) {
+	d.mu.Lock()
+	d.status = status
+	d.mu.Unlock()
+	d.emit(status)
+}
@@ -0,0 +1,181 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTransaction_Wait(t *testing.T) {
+	tx := NewTransaction([]*Service{
+		NewService(
+			"s1",
+			func() error { return nil },
+			func() error { return nil },
+			func() error { return nil },
+		),
+	})
+	if got := tx.Status(); got != StatusNotStarted {
+		t.Errorf("Transaction.Status() = %v, want %v", got, StatusNotStarted)
+	}
+	tx.Start()
+	tx.Start()
+	if err := tx.Wait(); err != nil {
+		t.Errorf("Transaction.Wait() error = %v", err)
+	}
+	if got := tx.Status(); got != StatusConfirmed {
+		t.Errorf("Transaction.Status() = %v, want %v", got, StatusConfirmed)
+	}
+}
+
+func TestTransaction_Abort(t *testing.T) {
+	t.Run("before start", func(t *testing.T) {
+		var tried bool
+		tx := NewTransaction([]*Service{
+			NewService(
+				"s1",
+				func() error { tried = true; return nil },
+				func() error { return nil },
+				func() error { return nil },
+			),
+		})
+		tx.Abort()
+		if err := tx.Wait(); !errors.Is(err, ErrAborted) {
+			t.Errorf("Transaction.Wait() error = %v, want %v", err, ErrAborted)
+		}
+		if tried {
+			t.Errorf("try() is called")
+		}
+		if got := tx.Status(); got != StatusCanceled {
+			t.Errorf("Transaction.Status() = %v, want %v", got, StatusCanceled)
+		}
+	})
+
+	t.Run("in try phase", func(t *testing.T) {
+		var confirmed, canceled bool
+		trying, proceed := make(chan struct{}), make(chan struct{})
+		tx := NewTransaction([]*Service{
+			NewService(
+				"s1",
+				func() error { close(trying); <-proceed; return nil },
+				func() error { confirmed = true; return nil },
+				func() error { canceled = true; return nil },
+			),
+		})
+		tx.Start()
+		<-trying
+		if got := tx.Status(); got != StatusTrying {
+			t.Errorf("Transaction.Status() = %v, want %v", got, StatusTrying)
+		}
+		tx.Abort()
+		close(proceed)
+		if err := tx.Wait(); !errors.Is(err, ErrAborted) {
+			t.Errorf("Transaction.Wait() error = %v, want %v", err, ErrAborted)
+		}
+		if confirmed || !canceled {
+			t.Errorf("confirmed = %v, canceled = %v, want canceled only", confirmed, canceled)
+		}
+	})
+
+	t.Run("interrupts hung try", func(t *testing.T) {
+		trying := make(chan struct{})
+		tx := NewTransaction([]*Service{
+			NewServiceContext(
+				"s1",
+				func(ctx context.Context) error { close(trying); <-ctx.Done(); return ctx.Err() },
+				func(ctx context.Context) error { return nil },
+				func(ctx context.Context) error { return nil },
+			),
+		})
+		tx.Start()
+		<-trying
+		tx.Abort()
+		if err := tx.Wait(); !errors.Is(err, ErrAborted) {
+			t.Errorf("Transaction.Wait() error = %v, want %v", err, ErrAborted)
+		}
+	})
+
+	t.Run("in confirm phase", func(t *testing.T) {
+		confirming, proceed := make(chan struct{}), make(chan struct{})
+		tx := NewTransaction([]*Service{
+			NewService(
+				"s1",
+				func() error { return nil },
+				func() error { close(confirming); <-proceed; return nil },
+				func() error { return nil },
+			),
+		})
+		tx.Start()
+		<-confirming
+		tx.Abort()
+		close(proceed)
+		if err := tx.Wait(); err != nil {
+			t.Errorf("Transaction.Wait() error = %v", err)
+		}
+		if got := tx.Status(); got != StatusConfirmed {
+			t.Errorf("Transaction.Status() = %v, want %v", got, StatusConfirmed)
+		}
+	})
+}
+
+func TestTransaction_Resume(t *testing.T) {
+	tests := []struct {
+		name       string
+		tryErr     error
+		wantStatus Status
+	}{
+		{
+			name:       "confirm",
+			wantStatus: StatusConfirmed,
+		},
+		{
+			name:       "cancel",
+			tryErr:     errors.New("test"),
+			wantStatus: StatusCanceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls map[string]int
+			broken := true
+			phase := func(name string) func() error {
+				return func() error {
+					calls[name]++
+					if broken {
+						return errors.New("test")
+					}
+					return nil
+				}
+			}
+			calls = map[string]int{}
+			tx := NewTransaction([]*Service{
+				NewService("s1", func() error { return nil }, phase("confirm s1"), phase("cancel s1")),
+				NewService("s2", func() error { return tt.tryErr }, func() error { return nil }, func() error { return nil }),
+			}, WithMaxRetries(0))
+			if err := tx.Resume(); err != ErrNotResumable {
+				t.Errorf("Transaction.Resume() error = %v, want %v", err, ErrNotResumable)
+			}
+			if err := tx.Wait(); err == nil {
+				t.Fatalf("Transaction.Wait() error = nil")
+			}
+			if got := tx.Status(); got != StatusFailed {
+				t.Errorf("Transaction.Status() = %v, want %v", got, StatusFailed)
+			}
+			broken = false
+			if err := tx.Resume(); err != nil {
+				t.Errorf("Transaction.Resume() error = %v", err)
+			}
+			if got := tx.Status(); got != tt.wantStatus {
+				t.Errorf("Transaction.Status() = %v, want %v", got, tt.wantStatus)
+			}
+			if err := tx.Resume(); err != ErrNotResumable {
+				t.Errorf("Transaction.Resume() error = %v, want %v", err, ErrNotResumable)
+			}
+			for name, n := range calls {
+				if n != 2 {
+					t.Errorf("%v called %v times, want 2", name, n)
+				}
+			}
+		})
+	}
+}
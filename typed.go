@@ -0,0 +1,46 @@
+package tcc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// NewTypedService returns service whose try returns result of type T passed to confirm and cancel,
+// like NewServiceWithResult without encoding the result by hand.
+// The result is encoded by encoding/json to be saved to Store, so T has to survive the round trip.
+// If the process crashed in try phase, cancel of a recovered transaction can receive the zero value of T.
+func NewTypedService[T any](
+	name string,
+	try func(ctx context.Context) (T, error),
+	confirm, cancel func(ctx context.Context, tryResult T) error,
+	opts ...ServiceOption,
+) *Service {
+	return NewServiceWithResult(name, encodeResult(try), decodeResult(confirm), decodeResult(cancel), opts...)
+}
+
+func encodeResult[T any](try func(ctx context.Context) (T, error)) TryFunc {
+	return func(ctx context.Context) ([]byte, error) {
+		result, err := try(ctx)
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			return nil, WithCode(fmt.Errorf("encode try result: %w", err), CodePermanent)
+		}
+		return b, nil
+	}
+}
+
+func decodeResult[T any](f func(ctx context.Context, tryResult T) error) ResultFunc {
+	return func(ctx context.Context, tryResult []byte) error {
+		var result T
+		if len(tryResult) > 0 {
+			if err := json.Unmarshal(tryResult, &result); err != nil {
+				return WithCode(fmt.Errorf("decode try result: %w", err), CodePermanent)
+			}
+		}
+		return f(ctx, result)
+	}
+}
@@ -0,0 +1,66 @@
+package tcc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type reservation struct {
+	ID     string
+	Amount int
+}
+
+func TestNewTypedService(t *testing.T) {
+	tests := []struct {
+		name   string
+		tryErr error
+		want   map[string]reservation
+	}{
+		{
+			name: "confirm receives try result",
+			want: map[string]reservation{"confirm": {ID: "r1", Amount: 3}},
+		},
+		{
+			name:   "cancel receives try result",
+			tryErr: errors.New("test"),
+			want:   map[string]reservation{"cancel": {ID: "r1", Amount: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]reservation{}
+			record := func(phase string) func(context.Context, reservation) error {
+				return func(ctx context.Context, r reservation) error {
+					got[phase] = r
+					return nil
+				}
+			}
+			d := NewDirector([]*Service{
+				NewTypedService(
+					"s1",
+					func(context.Context) (reservation, error) { return reservation{ID: "r1", Amount: 3}, nil },
+					record("confirm"),
+					record("cancel"),
+				),
+				NewService("s2", func() error { return tt.tryErr }, func() error { return nil }, func() error { return nil }),
+			}, WithDeterministicSchedule(1))
+			_ = d.Direct()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("results = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeResult(t *testing.T) {
+	var got reservation
+	f := decodeResult(func(ctx context.Context, r reservation) error { got = r; return nil })
+	if err := f(context.Background(), nil); err != nil || got != (reservation{}) {
+		t.Errorf("decodeResult() of nil = %v, %v, want zero value", got, err)
+	}
+	if err := f(context.Background(), []byte("{")); CodeOf(err) != CodePermanent {
+		t.Errorf("decodeResult() of broken result error = %v, want %v", err, CodePermanent)
+	}
+}